@@ -0,0 +1,95 @@
+package dotenv
+
+import (
+	"io"
+	"strings"
+)
+
+const annotationPrefix = "@"
+
+// Entry is a parsed value along with the annotations of the comments above
+// its assignment
+type Entry struct {
+	Value string
+	// Annotations holds the @key: value comments directly above the
+	// assignment, an annotation without a colon maps to an empty value
+	Annotations map[string]string
+}
+
+// ParseAnnotated reads env statements from r along with their annotation
+// comments using the default options
+func ParseAnnotated(r io.Reader) (map[string]Entry, error) {
+	return NewLoader(DefaultOptions()).ParseAnnotated(r)
+}
+
+// ParseAnnotated reads env statements from r along with their annotation
+// comments, e.g.
+//
+//	# @description: the database URL
+//	# @required
+//	DATABASE_URL=postgres://localhost
+//
+// Only the comment block directly above an assignment is considered, a key
+// assigned more than once keeps the entry of its last assignment
+func (l *Loader) ParseAnnotated(r io.Reader) (map[string]Entry, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]Entry)
+	p := l.newParser("", src)
+	pos := 0
+	for {
+		key, value, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return out, nil
+		}
+
+		entry := Entry{Value: value, Annotations: parseAnnotations(string(p.src[pos:p.start]), pos == 0)}
+		pos = p.end
+
+		if l.isUnset(value) {
+			delete(out, key)
+			continue
+		}
+		out[key] = entry
+	}
+}
+
+// parseAnnotations reads the annotations of the comment lines ending gap,
+// the text between two statements. Unless atStart, the first line of gap is
+// the tail of the previous statement line and never holds annotations
+func parseAnnotations(gap string, atStart bool) map[string]string {
+	lines := strings.Split(gap, "\n")
+	// the last line is the indentation of the statement itself
+	lines = lines[:len(lines)-1]
+	if !atStart && len(lines) > 0 {
+		lines = lines[1:]
+	}
+
+	out := make(map[string]string)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimFunc(lines[i], isSpace)
+		if line == "" || line[0] != charComment {
+			break
+		}
+
+		text := strings.TrimFunc(line[1:], isSpace)
+		if !strings.HasPrefix(text, annotationPrefix) {
+			continue
+		}
+
+		name, value, _ := strings.Cut(text[len(annotationPrefix):], ":")
+		name = strings.TrimFunc(name, isSpace)
+		if _, ok := out[name]; name != "" && !ok {
+			// the line closest to the assignment wins
+			out[name] = strings.TrimFunc(value, isSpace)
+		}
+	}
+
+	return out
+}
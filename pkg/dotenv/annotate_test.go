@@ -0,0 +1,44 @@
+package dotenv
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotated(t *testing.T) {
+	src := "# @description: ignored, a blank line ends the block\n" +
+		"\n" +
+		"# @description: the database URL\n" +
+		"# plain comment\n" +
+		"# @required\n" +
+		"# @example: postgres://user@host:5432/db\n" +
+		"# @required: closest wins\n" +
+		"DATABASE_URL=postgres://localhost # @trailing: not an annotation\n" +
+		"PORT=5432\n" +
+		"NAME=caf\xe9\n"
+
+	got, err := ParseAnnotated(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Entry{
+		"DATABASE_URL": {Value: "postgres://localhost", Annotations: map[string]string{
+			"description": "the database URL",
+			"required":    "closest wins",
+			"example":     "postgres://user@host:5432/db",
+		}},
+		"PORT": {Value: "5432", Annotations: map[string]string{}},
+		"NAME": {Value: "caf\xe9", Annotations: map[string]string{}},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d entries, want %d", len(got), len(want))
+	}
+	for k, w := range want {
+		g := got[k]
+		if g.Value != w.Value || !maps.Equal(g.Annotations, w.Annotations) {
+			t.Errorf("%s: got %+v, want %+v", k, g, w)
+		}
+	}
+}
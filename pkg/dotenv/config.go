@@ -0,0 +1,104 @@
+package dotenv
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+)
+
+// Config holds merged env files without touching the environment
+type Config struct {
+	vars map[string]string
+}
+
+// LoadConfig merges env files by path, in order of precedence, into a
+// Config using the default options
+func LoadConfig(path ...string) (*Config, error) {
+	return NewLoader(DefaultOptions()).LoadConfig(path...)
+}
+
+// LoadConfig merges env files by path, in order of precedence, into a
+// Config. Unlike LoadEnv neither the environment nor the working directory is
+// changed, and real environment variables do not win over the files
+func (l *Loader) LoadConfig(path ...string) (*Config, error) {
+	envMap, err := l.readFiles(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{vars: envMap}, nil
+}
+
+// Map returns a copy of the merged variables
+func (c *Config) Map() map[string]string {
+	return maps.Clone(c.vars)
+}
+
+// Lookup returns the value of key and whether it is set
+func (c *Config) Lookup(key string) (string, bool) {
+	v, ok := c.vars[key]
+	return v, ok
+}
+
+// String returns the value of key, empty when it is not set
+func (c *Config) String(key string) string {
+	return c.vars[key]
+}
+
+// Int parses the value of key as a base 10 int, a key that is not set is an
+// error
+func (c *Config) Int(key string) (int, error) {
+	v, err := c.required(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+// Bool parses the value of key as strconv.ParseBool does, a key that is not
+// set is an error
+func (c *Config) Bool(key string) (bool, error) {
+	v, err := c.required(key)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+// Duration parses the value of key as time.ParseDuration does, a key that is
+// not set is an error
+func (c *Config) Duration(key string) (time.Duration, error) {
+	v, err := c.required(key)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func (c *Config) required(key string) (string, error) {
+	v, ok := c.vars[key]
+	if !ok {
+		return "", fmt.Errorf("variable %s is not set", key)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,78 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	t.Setenv("CFG_NAME", "env")
+	unsetenv(t, "CFG_PORT")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":      "CFG_NAME=app\nCFG_PORT=80\nCFG_DEBUG=true\nCFG_TIMEOUT=1m30s\nCFG_EMPTY=\nCFG_BAD=x\n",
+		".env.test": "CFG_PORT=8080\n",
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("working directory changed to %s", now)
+	}
+	if v, ok := os.LookupEnv("CFG_PORT"); ok {
+		t.Errorf("CFG_PORT = %q, want the environment untouched", v)
+	}
+
+	if got := c.String("CFG_NAME"); got != "app" {
+		t.Errorf("String = %q, want the file value", got)
+	}
+	if got := c.String("CFG_MISSING"); got != "" {
+		t.Errorf("String of a missing key = %q, want empty", got)
+	}
+	if v, ok := c.Lookup("CFG_EMPTY"); !ok || v != "" {
+		t.Errorf("Lookup of an empty key = %q, %v, want set", v, ok)
+	}
+	if _, ok := c.Lookup("CFG_MISSING"); ok {
+		t.Error("Lookup of a missing key reports it set")
+	}
+	if got, err := c.Int("CFG_PORT"); err != nil || got != 8080 {
+		t.Errorf("Int = %d, %v, want 8080", got, err)
+	}
+	if got, err := c.Bool("CFG_DEBUG"); err != nil || !got {
+		t.Errorf("Bool = %v, %v, want true", got, err)
+	}
+	if got, err := c.Duration("CFG_TIMEOUT"); err != nil || got != 90*time.Second {
+		t.Errorf("Duration = %s, %v, want 1m30s", got, err)
+	}
+
+	for name, get := range map[string]func(key string) error{
+		"Int":      func(key string) error { _, err := c.Int(key); return err },
+		"Bool":     func(key string) error { _, err := c.Bool(key); return err },
+		"Duration": func(key string) error { _, err := c.Duration(key); return err },
+	} {
+		if err := get("CFG_MISSING"); err == nil || err.Error() != "variable CFG_MISSING is not set" {
+			t.Errorf("%s of a missing key: got %v", name, err)
+		}
+		if err := get("CFG_BAD"); err == nil || !strings.HasPrefix(err.Error(), "CFG_BAD: ") {
+			t.Errorf("%s of an invalid value: got %v", name, err)
+		}
+	}
+
+	// the returned map is a copy
+	c.Map()["CFG_NAME"] = "changed"
+	if got := c.String("CFG_NAME"); got != "app" {
+		t.Errorf("String after changing Map = %q, want app", got)
+	}
+}
@@ -0,0 +1,156 @@
+package dotenv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// Document is an env file kept as written: editing a statement leaves
+// comments, blank lines and the other statements untouched
+type Document struct {
+	// chunks alternate between the text around statements and the
+	// statements themselves, in file order
+	chunks []chunk
+	crlf   bool
+}
+
+// Statement is a KEY=value assignment of a Document
+type Statement struct {
+	Key   string
+	Value string
+	// Exported reports whether the statement is written as export KEY=value
+	Exported bool
+	// Quoted reports whether the value is written quoted, telling KEY="" from
+	// KEY= apart
+	Quoted bool
+}
+
+// String formats the statement as an env file line
+func (s Statement) String() string {
+	value := quoteValue(s.Value)
+	if s.Quoted && !strings.HasPrefix(value, `"`) {
+		value = `"` + valueEscaper.Replace(s.Value) + `"`
+	}
+
+	text := quoteKey(s.Key) + "=" + value
+	if s.Exported {
+		text = exportPrefix + " " + text
+	}
+
+	return text
+}
+
+type chunk struct {
+	text string
+	stmt *Statement
+}
+
+// ParseDocument reads a Document from r using the default options
+func ParseDocument(r io.Reader) (*Document, error) {
+	return NewLoader(DefaultOptions()).ParseDocument(r)
+}
+
+// ParseDocument reads a Document from r
+func (l *Loader) ParseDocument(r io.Reader) (*Document, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{crlf: bytes.Contains(src, []byte("\r\n"))}
+
+	p := l.newParser("", src)
+	pos := 0
+	for {
+		key, value, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		doc.chunks = append(doc.chunks,
+			chunk{text: string(p.src[pos:p.start])},
+			chunk{text: string(p.src[p.start:p.end]), stmt: &Statement{Key: key, Value: value, Exported: p.exported, Quoted: p.quoted}},
+		)
+		pos = p.end
+	}
+	doc.chunks = append(doc.chunks, chunk{text: string(p.src[pos:])})
+
+	return doc, nil
+}
+
+// Statements returns the statements of the document in file order
+func (d *Document) Statements() []Statement {
+	var out []Statement
+	for _, c := range d.chunks {
+		if c.stmt != nil {
+			out = append(out, *c.stmt)
+		}
+	}
+
+	return out
+}
+
+// Get returns the value of the last statement assigning key
+func (d *Document) Get(key string) (string, bool) {
+	if c := d.last(key); c != nil {
+		return c.stmt.Value, true
+	}
+
+	return "", false
+}
+
+// Set rewrites the last statement assigning key, keeping its export prefix
+// and quoting, or appends a new one to the end of the document
+func (d *Document) Set(key, value string) error {
+	if err := validateKey(key); err != nil && !isQuotableKey(key) {
+		return err
+	}
+
+	if c := d.last(key); c != nil {
+		c.stmt.Value = value
+		c.text = c.stmt.String()
+		return nil
+	}
+	stmt := &Statement{Key: key, Value: value}
+
+	// keep the new statement on its own line
+	tail := &d.chunks[len(d.chunks)-1]
+	if (tail.text != "" || len(d.chunks) > 1) && !strings.HasSuffix(tail.text, "\n") {
+		tail.text += "\n"
+	}
+	d.chunks = append(d.chunks,
+		chunk{text: stmt.String(), stmt: stmt},
+		chunk{text: "\n"},
+	)
+
+	return nil
+}
+
+// Bytes renders the document back to env file content
+func (d *Document) Bytes() []byte {
+	var sb strings.Builder
+	for _, c := range d.chunks {
+		sb.WriteString(c.text)
+	}
+
+	out := []byte(sb.String())
+	if d.crlf {
+		out = bytes.ReplaceAll(out, []byte("\n"), []byte("\r\n"))
+	}
+
+	return out
+}
+
+func (d *Document) last(key string) *chunk {
+	for i := len(d.chunks) - 1; i >= 0; i-- {
+		if c := &d.chunks[i]; c.stmt != nil && c.stmt.Key == key {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package dotenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"# database\nDB_HOST=localhost\nDB_PORT=5432\n\n\n# cache\nCACHE_URL=redis://x # inline\n\nexport DEBUG=\"1\"\n",
+		"\n\nA=1\n\n",
+		"NAME=caf\xe9\nB=2\n",
+		"A=1\r\n\r\nB='two'\r\n",
+		"A=1",
+	} {
+		doc, err := ParseDocument(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		if got := string(doc.Bytes()); got != src {
+			t.Errorf("round trip: got %q, want %q", got, src)
+		}
+	}
+}
+
+func TestDocumentSetKeepsBlankLines(t *testing.T) {
+	const src = "# section one\nA=1\n\n\n# section two\nB=2 # note\n\nC=caf\xe9\n"
+
+	doc, err := ParseDocument(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("B", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("C", "tea"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# section one\nA=1\n\n\n# section two\nB=changed # note\n\nC=tea\n"
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDocumentLatin1Value(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader("NAME=caf\xe9\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := doc.Get("NAME"); got != "caf\xe9" {
+		t.Errorf("got %q, want the raw bytes", got)
+	}
+}
+
+func TestDocumentExported(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader("export A=1\nB=2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmts := doc.Statements()
+	if len(stmts) != 2 || !stmts[0].Exported || stmts[1].Exported {
+		t.Fatalf("got %+v, want only A exported", stmts)
+	}
+
+	if err := doc.Set("A", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("C", "4"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(doc.Bytes()), "export A=3\nB=2\nC=4\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDocumentEmptyQuoted(t *testing.T) {
+	const src = "QUOTED=\"\"\nSINGLE=''\nBARE=\n"
+
+	doc, err := ParseDocument(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range doc.Statements() {
+		if stmt.Value != "" || stmt.Quoted != (stmt.Key != "BARE") {
+			t.Errorf("got %+v", stmt)
+		}
+	}
+
+	// rewriting keeps the quoting of the empty forms
+	for _, key := range []string{"QUOTED", "BARE"} {
+		if err := doc.Set(key, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := string(doc.Bytes()), src; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := (Statement{Key: "K", Quoted: true}).String(), `K=""`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
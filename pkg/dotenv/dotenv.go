@@ -2,128 +2,568 @@ package dotenv
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"github.com/KoNekoD/rootpath/pkg/rootpath"
 	"io"
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 const (
 	charComment       = '#'
+	charSemicolon     = ';'
 	prefixSingleQuote = '\''
 	prefixDoubleQuote = '"'
 
-	exportPrefix = "export"
+	exportPrefix  = "export"
+	heredocPrefix = "<<"
+	tripleQuote   = `"""`
+	appendSuffix  = "[]"
+	listSeparator = ","
+	redactedValue = "***"
+
+	builtinFile = "__FILE__"
+	builtinLine = "__LINE__"
 )
 
 var (
 	EnvKey     = "APP_ENV"
 	DefaultEnv = "dev"
+	// BuildEnv is the environment used when APP_ENV is unset, before
+	// DefaultEnv. Set it at build time, e.g.
+	// -ldflags "-X github.com/KoNekoD/dotenv/pkg/dotenv.BuildEnv=prod"
+	BuildEnv string
+
+	escapeRegex = regexp.MustCompile(`(?s)\\(x[0-9A-Fa-f]{2}|0[0-7]{2}|.)`)
+	// names may hold digits anywhere, so indexed keys such as ${SERVER_0} and
+	// $SERVER_10 expand as a whole
+	expandVarRegex = regexp.MustCompile(`(\\)?(\$)(\()?\{?([A-Z0-9_]+)?\}?`)
+	posixKeyRegex  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
-	escapeRegex        = regexp.MustCompile(`\\.`)
-	expandVarRegex     = regexp.MustCompile(`(\\)?(\$)(\()?\{?([A-Z0-9_]+)?\}?`)
-	unescapeCharsRegex = regexp.MustCompile(`\\([^$])`)
+	gzipMagic = []byte{0x1f, 0x8b}
 )
 
 // LoadEnv loads env files by path, in order of precedence
 func LoadEnv(path ...string) error {
-	rootpath.MustChdir()
+	return NewLoader(DefaultOptions()).LoadEnv(path...)
+}
 
-	if len(path) != 1 {
-		path = []string{".env"}
-	}
-	p := path[0]
+// LoadIfSet loads env files by path, in order of precedence, only when the
+// sentinel environment variable is non-empty, e.g. DOTENV_ENABLE=1
+func LoadIfSet(sentinel string, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadIfSet(sentinel, path...)
+}
 
-	var originalVarNames []string
-	for _, v := range os.Environ() {
-		originalVarNames = append(originalVarNames, strings.Split(v, "=")[0])
-	}
+// LoadEnvKeys loads env files by path, in order of precedence, applying only
+// the listed keys
+func LoadEnvKeys(keys []string, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadEnvKeys(keys, path...)
+}
 
-	filesFn := []func() string{
-		func() string { return fmt.Sprintf("%s", p) },
-		func() string { return fmt.Sprintf("%s.local", p) },
-		func() string { return fmt.Sprintf("%s.%s", p, appEnv()) },
-		func() string { return fmt.Sprintf("%s.%s.local", p, appEnv()) },
-	}
+// LoadRequired loads env files by path, in order of precedence, failing
+// when any of the required keys ends up unset or empty
+func LoadRequired(required []string, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadRequired(required, path...)
+}
+
+// LoadEnvRaw loads env files by path, in order of precedence, returning the
+// content of every file read as stored on disk
+func LoadEnvRaw(path ...string) (map[string][]byte, error) {
+	return NewLoader(DefaultOptions()).LoadEnvRaw(path...)
+}
 
-	for _, f := range filesFn {
-		file := f()
+// LoadEnvTimeout loads env files by path, in order of precedence, failing
+// when reading them takes longer than d
+func LoadEnvTimeout(d time.Duration, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadEnvTimeout(d, path...)
+}
 
-		individualEnvMap, individualErr := readFile(file)
-		if individualErr != nil {
-			return individualErr
-		}
-		for k, v := range individualEnvMap {
-			if !slices.Contains(originalVarNames, k) {
-				_ = os.Setenv(k, v)
-			}
+// LoadIntoSyncMap loads env files by path, in order of precedence, into m
+// instead of the environment
+func LoadIntoSyncMap(m *sync.Map, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadIntoSyncMap(m, path...)
+}
+
+// LoadEnvDir loads env files by path relative to dir, in order of precedence
+func LoadEnvDir(dir string, path ...string) error {
+	return NewLoader(DefaultOptions()).LoadEnvDir(dir, path...)
+}
+
+// ParseInline parses statements separated by newlines or semicolons, e.g.
+// "A=1;B='two'", using the default options
+func ParseInline(s string) (map[string]string, error) {
+	return NewLoader(DefaultOptions()).ParseInline(s)
+}
+
+// LoadChain loads env files for each base path in turn, later bases taking
+// precedence over the former
+func LoadChain(bases ...string) error {
+	return NewLoader(DefaultOptions()).LoadChain(bases...)
+}
+
+// LoadGlob loads the env files matching pattern in sorted order, later files
+// taking precedence over the former
+func LoadGlob(pattern string) error {
+	return NewLoader(DefaultOptions()).LoadGlob(pattern)
+}
+
+// LoadFiles loads the listed env files in order, later files taking
+// precedence over the former
+func LoadFiles(paths ...string) error {
+	return NewLoader(DefaultOptions()).LoadFiles(paths...)
+}
+
+// LoadManifest loads the env files listed in the manifest file in order,
+// later files taking precedence over the former
+func LoadManifest(manifestPath string) error {
+	return NewLoader(DefaultOptions()).LoadManifest(manifestPath)
+}
+
+// CandidateFiles returns the env files LoadEnv tries for base path p, in
+// order of precedence
+func CandidateFiles(p string) []string {
+	return NewLoader(DefaultOptions()).CandidateFiles(p)
+}
+
+// Expand replaces ${VAR} references in s with values from the environment
+func Expand(s string) string {
+	return ExpandWith(s, nil)
+}
+
+// ExpandWith replaces ${VAR} references in s with values from vars, falling
+// back to the environment
+func ExpandWith(s string, vars map[string]string) string {
+	return expandVariables(s, func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
 		}
-	}
 
-	return nil
+		return os.LookupEnv(name)
+	})
 }
 
+// appEnv returns the environment of the app, APP_ENV falling back to
+// BuildEnv, then DefaultEnv
 func appEnv() string {
 	env := os.Getenv(EnvKey)
 	if env == "" {
-		env, _ = DefaultEnv, os.Setenv(EnvKey, DefaultEnv)
+		env = BuildEnv
+		if env == "" {
+			env = DefaultEnv
+		}
 	}
 
 	return env
 }
 
-func readFile(filename string) (map[string]string, error) {
-	file, err := os.Open(filename)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
+// setAppEnv sets APP_ENV to the environment of the app when it is unset
+func setAppEnv() {
+	if os.Getenv(EnvKey) == "" {
+		_ = os.Setenv(EnvKey, appEnv())
+	}
+}
+
+// lookupEnv looks up name in the environment, an unset APP_ENV resolves to
+// the environment of the app
+func lookupEnv(name string) (string, bool) {
+	if name == EnvKey {
+		return appEnv(), true
+	}
+
+	return os.LookupEnv(name)
+}
+
+// readFile parses filename, a missing file is treated as empty. raw is the
+// content of the file as stored on disk
+func (l *Loader) readFile(filename string, secret bool) (envMap map[string]string, raw []byte, err error) {
+	envMap, raw, err = l.parseFile(filename, secret)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil, nil
+	}
+
+	return envMap, raw, err
+}
+
+// parseFile parses filename, raw is its content as stored on disk. A secret
+// file must not be accessible by group or others
+func (l *Loader) parseFile(filename string, secret bool) (envMap map[string]string, raw []byte, err error) {
+	raw, err = l.readRaw(filename, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := l.decode(filename, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envMap, err = l.parseBytes(filename, src)
+	return envMap, raw, err
+}
+
+// readSource reads filename, decompressing it if needed
+func (l *Loader) readSource(filename string) ([]byte, error) {
+	raw, err := l.readRaw(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.decode(filename, raw)
+}
+
+// decode returns the env file content of raw, gzip-compressed files are
+// decompressed transparently
+func (l *Loader) decode(filename string, raw []byte) ([]byte, error) {
+	if bytes.HasPrefix(raw, gzipMagic) {
+		return l.gunzip(filename, raw)
+	}
+
+	return raw, nil
+}
+
+// readRaw reads filename as stored on disk, enforcing Options.MaxFileSize.
+// The mode of a secret file is checked on the opened file, so that it cannot
+// be swapped after the check
+func (l *Loader) readRaw(filename string, secret bool) ([]byte, error) {
+	file, err := l.open(filename)
+	if err != nil {
 		return nil, err
-	} else if errors.Is(err, os.ErrNotExist) {
-		return make(map[string]string), nil
 	}
 	defer func() { _ = file.Close() }()
 
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: path is a directory, expected a file", filename)
+	}
+	if secret {
+		if err = checkSecretMode(filename, info); err != nil {
+			return nil, err
+		}
+	}
+
+	var r io.Reader = file
+	if maxSize := l.opts.MaxFileSize; maxSize > 0 {
+		if info.Size() > maxSize {
+			return nil, fmt.Errorf("%s: file size %d exceeds the limit of %d bytes", filename, info.Size(), maxSize)
+		}
+		// the file may grow after Stat
+		r = io.LimitReader(file, maxSize+1)
+	}
+
 	var buf bytes.Buffer
-	_, err = io.Copy(&buf, file)
+	_, err = io.Copy(&buf, r)
 	if err != nil {
 		return nil, err
 	}
+	if maxSize := l.opts.MaxFileSize; maxSize > 0 && int64(buf.Len()) > maxSize {
+		return nil, fmt.Errorf("%s: file size exceeds the limit of %d bytes", filename, maxSize)
+	}
+
+	// an empty file still reads as non-nil, unlike a missing one
+	if buf.Len() == 0 {
+		return []byte{}, nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gunzip decompresses the content of filename, enforcing Options.MaxFileSize
+// on the decompressed size
+func (l *Loader) gunzip(filename string, src []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var r io.Reader = zr
+	if maxSize := l.opts.MaxFileSize; maxSize > 0 {
+		r = io.LimitReader(zr, maxSize+1)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if maxSize := l.opts.MaxFileSize; maxSize > 0 && int64(len(out)) > maxSize {
+		return nil, fmt.Errorf("%s: decompressed size exceeds the limit of %d bytes", filename, maxSize)
+	}
+
+	return out, nil
+}
+
+// parser holds the state of a single parse run
+type parser struct {
+	l    *Loader
+	opts *Options
+	vars map[string]string
+
+	// filename and src are the file being parsed, for diagnostics
+	filename string
+	src      []byte
+	// crlf holds the offsets in src of the \n of every \r\n line end of the
+	// input, src has them normalized to \n
+	crlf []int
+	// cutset is the source left to parse
+	cutset []byte
+	// inline additionally separates statements by semicolons
+	inline bool
+	// arg parses a command line argument, a # in it never starts a comment
+	arg bool
+	// start and end are the offsets of the last statement parsed in src,
+	// a trailing comment is not part of it
+	start, end int
+	// exported reports whether the last statement used the export prefix,
+	// quoted whether its value was quoted
+	exported, quoted bool
+	// undefined holds the ${VAR} references no source defines, in order
+	undefined []string
+	// key is the variable name of the statement being parsed
+	key string
+}
+
+func (l *Loader) newParser(filename string, src []byte) *parser {
+	var crlf []int
+	if bytes.Contains(src, []byte("\r\n")) {
+		normalized := make([]byte, 0, len(src))
+		for i, c := range src {
+			if c == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+				crlf = append(crlf, len(normalized))
+				continue
+			}
+			normalized = append(normalized, c)
+		}
+		src = normalized
+	}
+
+	return &parser{
+		l:        l,
+		opts:     &l.opts,
+		vars:     make(map[string]string),
+		filename: filename,
+		src:      src,
+		crlf:     crlf,
+		cutset:   src,
+	}
+}
+
+// offset returns the offset in src of the position rest starts at
+func (p *parser) offset(rest []byte) int {
+	return len(p.src) - len(rest)
+}
+
+// inputOffset maps offset o in src back to the input before \r\n line ends
+// were normalized
+func (p *parser) inputOffset(o int) int {
+	n, _ := slices.BinarySearch(p.crlf, o+1)
+	return o + n
+}
+
+// lineAt returns the line number of the position rest starts at
+func (p *parser) lineAt(rest []byte) int {
+	return bytes.Count(p.src[:p.offset(rest)], []byte("\n")) + 1
+}
+
+// warn records a non-fatal problem found at the position rest starts at
+func (p *parser) warn(rest []byte, format string, args ...any) {
+	p.l.warnings = append(p.l.warnings, Warning{
+		File: p.filename,
+		Line: p.lineAt(rest),
+		Msg:  fmt.Sprintf(format, args...),
+	})
+}
+
+// lookup resolves a ${VAR} reference from the expansion sources in order
+func (p *parser) lookup(name string) (string, bool) {
+	if p.opts.BuiltinVars {
+		switch name {
+		case builtinFile:
+			return p.filename, true
+		case builtinLine:
+			return strconv.Itoa(p.lineAt(p.cutset)), true
+		}
+	}
+
+	if target, ok := p.opts.ExpandAliases[name]; ok {
+		name = target
+	}
+
+	sources := p.opts.ExpandSources
+	if sources == nil {
+		sources = defaultExpandSources
+	}
+
+	for _, source := range sources {
+		var (
+			value string
+			ok    bool
+		)
+		switch source {
+		case SourceFile:
+			value, ok = p.vars[name]
+		case SourceVars:
+			value, ok = p.opts.ExpandVars[name]
+		case SourceOS:
+			value, ok = lookupEnv(name)
+		case SourceResolver:
+			if p.opts.Resolver != nil {
+				value, ok = p.opts.Resolver(name)
+			}
+		}
+		if ok {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// expand replaces ${VAR} references in v, recording the undefined ones, then
+// executes it as a template with Options.TemplateExpansion
+func (p *parser) expand(v string) (string, error) {
+	out, undefined := expandReferences(v, p.lookup, p.opts.OnUndefined == UndefinedKeepLiteral)
+	if len(undefined) > 0 && p.opts.OnUndefined == UndefinedError {
+		return "", fmt.Errorf("line %d: undefined variable %s", p.lineAt(p.cutset), undefined[0])
+	}
+	p.undefined = append(p.undefined, undefined...)
+	if p.opts.TemplateExpansion {
+		var err error
+		if out, err = p.executeTemplate(out); err != nil {
+			return "", err
+		}
+	}
+	// redacting costs a pass over the secret patterns, skip it without Debug
+	if out != v && p.opts.Debug != nil {
+		p.trace(p.cutset, "expanded %s to %s", p.redact(v), p.redact(out))
+	}
 
-	out := make(map[string]string)
+	return out, nil
+}
+
+// indented reports whether the statement starting at offset start is
+// preceded by whitespace on its line
+func (p *parser) indented(start int) bool {
+	i := start
+	for i > 0 && isSpace(rune(p.src[i-1])) {
+		i--
+	}
+
+	return i < start && (i == 0 || p.src[i-1] == '\n')
+}
 
-	src := buf.Bytes()
+// trace emits a parsing event to Options.Debug for the position rest starts
+// at
+func (p *parser) trace(rest []byte, format string, args ...any) {
+	if p.opts.Debug == nil {
+		return
+	}
+
+	p.opts.Debug(fmt.Sprintf("line %d: %s", p.lineAt(rest), fmt.Sprintf(format, args...)))
+}
+
+func (l *Loader) parseBytes(filename string, src []byte) (map[string]string, error) {
+	p := l.newParser(filename, src)
+	for {
+		_, _, ok, err := p.next()
+		if err != nil || !ok {
+			return p.vars, err
+		}
+	}
+}
 
-	src = bytes.Replace(src, []byte("\r\n"), []byte("\n"), -1)
-	cutset := src
+// next parses the next statement, ok is false once the source is exhausted
+func (p *parser) next() (key, value string, ok bool, err error) {
 	for {
-		if cutset = getStatementStart(cutset); cutset == nil {
+		if p.cutset = p.getStatementStart(p.cutset); p.cutset == nil {
+			return "", "", false, nil
+		}
+
+		left, skipped := p.skipBareExport(p.cutset)
+		if !skipped {
 			break
 		}
+		p.cutset = left
+	}
+	p.start = p.offset(p.cutset)
+	if p.opts.NoLeadingWhitespace && p.indented(p.start) {
+		return "", "", false, fmt.Errorf("line %d: unexpected indentation before %q", p.lineAt(p.cutset), firstLine(p.cutset))
+	}
+
+	key, left, err := p.locateKeyName(p.cutset)
+	var keyErr *InvalidKeyError
+	if p.opts.SkipInvalid && errors.As(err, &keyErr) {
+		line := firstLine(p.cutset)
+		p.warn(p.cutset, "skipped %q: unexpected character %q in variable name", keyErr.Near, string(keyErr.Char))
+		p.cutset = p.cutset[len(line):]
+
+		return p.next()
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+
+	if replacement, ok := p.opts.Deprecated[key]; ok {
+		p.warn(p.cutset, "%s is deprecated, use %s instead", key, replacement)
+	}
+
+	p.key, p.end, p.quoted = key, -1, false
+	value, left, err = p.extractVarValue(left)
+	if err != nil {
+		return "", "", false, err
+	}
 
-		key, left, err := locateKeyName(cutset)
+	if prefix := p.opts.DecodeBase64Prefix; prefix != "" && strings.HasPrefix(value, prefix) {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
 		if err != nil {
-			return out, err
+			return "", "", false, fmt.Errorf("invalid base64 value of %s: %w", key, err)
 		}
+		value = string(decoded)
+	}
 
-		value, left, err := extractVarValue(left, out)
-		if err != nil {
-			return out, err
+	// values keep their bytes as is, a trailing comment is not checked
+	if p.opts.RequireUTF8 && !utf8.ValidString(value) {
+		return "", "", false, fmt.Errorf("line %d: invalid UTF-8 in value of %s", p.lineAt(p.cutset), key)
+	}
+
+	if p.opts.AllowAppend && strings.HasSuffix(key, appendSuffix) {
+		key = strings.TrimRightFunc(strings.TrimSuffix(key, appendSuffix), isSpace)
+		if prev := p.vars[key]; prev != "" {
+			value = prev + listSeparator + value
 		}
+	}
 
-		out[key], cutset = value, left
+	p.vars[key], p.cutset = value, left
+	if p.end == -1 {
+		p.end = p.offset(left)
 	}
 
-	return out, err
+	return key, value, true, nil
 }
 
-func extractVarValue(src []byte, vars map[string]string) (value string, rest []byte, err error) {
+func (p *parser) extractVarValue(src []byte) (value string, rest []byte, err error) {
+	if p.opts.AllowHeredoc && bytes.HasPrefix(src, []byte(heredocPrefix)) {
+		return p.extractHeredoc(src)
+	}
+	if p.opts.AllowTripleQuotes && bytes.HasPrefix(src, []byte(tripleQuote)) {
+		return p.extractTripleQuoted(src)
+	}
+
 	quote, hasPrefix := hasQuotePrefix(src)
+	p.quoted = hasPrefix
 	if !hasPrefix {
 		// unquoted value - read until end of line
-		endOfLine := bytes.IndexFunc(src, isLineEnd)
+		endOfLine := bytes.IndexFunc(src, p.isStatementEnd)
 
 		// Hit EOF without a trailing newline
 		if endOfLine == -1 {
@@ -134,8 +574,9 @@ func extractVarValue(src []byte, vars map[string]string) (value string, rest []b
 			}
 		}
 
-		// Convert line to rune away to do accurate countback of runes
-		line := []rune(string(src[0:endOfLine]))
+		// the line is scanned as bytes so that offsets stay exact and invalid
+		// UTF-8 is kept as is
+		line := src[0:endOfLine]
 
 		// Assume end of line is end of var
 		endOfVar := len(line)
@@ -143,20 +584,55 @@ func extractVarValue(src []byte, vars map[string]string) (value string, rest []b
 			return "", src[endOfLine:], nil
 		}
 
-		// Work backwards to check if the line ends in whitespace then
-		// a comment (ie asdasd # some comment)
-		for i := endOfVar - 1; i >= 0; i-- {
-			if line[i] == charComment && i > 0 {
-				if isSpace(line[i-1]) {
+		// Check if the line ends in whitespace then a comment (ie asdasd #
+		// some comment), the last such # wins. A # inside ${...} belongs to
+		// the reference, e.g. to the default of ${VAR:- a # b }
+		depth := 0
+		for i := 0; i < len(line); i++ {
+			switch c := line[i]; {
+			case c == '$' && i+1 < len(line) && line[i+1] == '{' && !isEscaped(line, i):
+				depth++
+				i++
+			case c == '{' && depth > 0:
+				depth++
+			case c == '}' && depth > 0:
+				depth--
+			case c == charComment && depth == 0 && i > 0 && !p.arg:
+				if r, _ := utf8.DecodeLastRune(line[:i]); isSpace(r) {
 					endOfVar = i
-					break
 				}
 			}
 		}
 
-		trimmed := strings.TrimFunc(string(line[0:endOfVar]), isSpace)
+		// keep the trailing comment out of the statement span
+		p.end = p.offset(src) + len(bytes.TrimRightFunc(line[0:endOfVar], isSpace))
+
+		// escaped \# never starts a comment and stays a literal #
+		trimmed := string(bytes.TrimFunc(line[0:endOfVar], isSpace))
+		if sigil := p.opts.LiteralSigil; sigil != 0 && len(trimmed) > 0 && trimmed[0] == sigil {
+			p.trace(src, "parsed %s (literal)", p.key)
+			return trimmed[1:], src[endOfLine:], nil
+		}
+		trimmed = strings.ReplaceAll(trimmed, `\#`, "#")
+		if p.opts.StrictQuotes {
+			if i := strings.IndexAny(trimmed, `"'`); i != -1 {
+				return "", nil, fmt.Errorf("unexpected quote %q in unquoted value %s", trimmed[i], p.redact(trimmed))
+			}
+		}
+		if p.opts.RequireQuotedSpaces && strings.ContainsFunc(trimmed, isSpace) {
+			return "", nil, fmt.Errorf("line %d: unquoted value %q contains spaces, quote it", p.lineAt(src), p.redact(trimmed))
+		}
+		if p.opts.ExpandEscapesUnquoted {
+			trimmed = expandEscapes(trimmed)
+		}
+		if p.opts.ExpandUnquoted {
+			if trimmed, err = p.expand(trimmed); err != nil {
+				return "", nil, err
+			}
+		}
 
-		return expandVariables(trimmed, vars), src[endOfLine:], nil
+		p.trace(src, "parsed %s (unquoted)", p.key)
+		return trimmed, src[endOfLine:], nil
 	}
 
 	// lookup quoted string terminator
@@ -166,117 +642,468 @@ func extractVarValue(src []byte, vars map[string]string) (value string, rest []b
 		}
 
 		// skip escaped quote symbol (\" or \', depends on quote)
-		if prevChar := src[i-1]; prevChar == '\\' {
+		if isEscaped(src, i) {
 			continue
 		}
 
+		// in recover mode a closing quote on a later line is taken as one
+		// opening another value, the real closing quote being missing
+		if p.opts.RecoverUnterminated && bytes.ContainsFunc(src[1:i], isLineEnd) {
+			return p.unterminatedQuote(src)
+		}
+
+		// a closing quote followed by more text on its line usually means
+		// the real closing quote is missing and this one opens another value,
+		// otherwise the text is parsed as the next statement
+		rest = src[i+1:]
+		p.end = p.offset(rest)
+		if !p.hasTrailingText(rest) {
+			rest = p.skipLine(rest)
+		} else if p.opts.StrictQuotes {
+			return p.unterminatedQuote(src)
+		}
+
 		// trim quotes
-		trimFunc := isCharFunc(rune(quote))
-		value = string(bytes.TrimLeftFunc(bytes.TrimRightFunc(src[0:i], trimFunc), trimFunc))
+		value = string(src[1:i])
 		if quote == prefixDoubleQuote {
 			// unescape newlines for double quote (this is compat feature)
 			// and expand environment variables
-			value = expandVariables(expandEscapes(value), vars)
+			value = expandEscapes(value)
+			if p.opts.ExpandInDoubleQuotes {
+				if value, err = p.expand(value); err != nil {
+					return "", nil, err
+				}
+			}
+		}
+
+		if quote == prefixDoubleQuote {
+			p.trace(src, "parsed %s (double-quoted)", p.key)
+		} else {
+			p.trace(src, "parsed %s (single-quoted)", p.key)
 		}
+		return value, rest, nil
+	}
+
+	return p.unterminatedQuote(src)
+}
+
+// redact hides value of the current statement in diagnostics with
+// Options.SafeErrors or when its key is a secret one
+func (p *parser) redact(value string) string {
+	if p.opts.SafeErrors || p.l.isSecretKey(p.key) {
+		return p.key + "=" + redactedValue
+	}
+
+	return value
+}
+
+// redactLine hides the value part of a whole statement line in diagnostics
+// with Options.SafeErrors, a line without separator is hidden entirely
+func (p *parser) redactLine(line string) string {
+	if !p.opts.SafeErrors {
+		return line
+	}
+	if i := strings.IndexAny(line, "=:"); i != -1 {
+		return line[:i+1] + redactedValue
+	}
+
+	return redactedValue
+}
+
+// redactName quotes a variable name for diagnostics, with Options.SafeErrors
+// it is hidden as a line without separator may be a pasted secret
+func (p *parser) redactName(name string) string {
+	if p.opts.SafeErrors {
+		return redactedValue
+	}
+
+	return strconv.Quote(name)
+}
+
+// firstLine returns src up to the end of its first line
+func firstLine(src []byte) []byte {
+	if endOfLine := bytes.IndexFunc(src, isLineEnd); endOfLine != -1 {
+		return src[:endOfLine]
+	}
 
-		return value, src[i+1:], nil
+	return src
+}
+
+// isEscaped reports whether src[i] is preceded by an odd number of
+// backslashes, so that "a\\" still terminates at its last quote
+func isEscaped(src []byte, i int) bool {
+	n := 0
+	for j := i - 1; j >= 0 && src[j] == '\\'; j-- {
+		n++
 	}
 
-	// return formatted error if quoted string is not terminated
+	return n%2 == 1
+}
+
+// unterminatedQuote returns formatted error if quoted string is not
+// terminated, in recover mode the rest of the line is taken as the value and
+// a warning is recorded instead
+func (p *parser) unterminatedQuote(src []byte) (value string, rest []byte, err error) {
 	valEndIndex := bytes.IndexFunc(src, isCharFunc('\n'))
 	if valEndIndex == -1 {
 		valEndIndex = len(src)
 	}
 
-	return "", nil, fmt.Errorf("unterminated quoted value %s", src[:valEndIndex])
+	if !p.opts.RecoverUnterminated {
+		return "", nil, fmt.Errorf("unterminated quoted value %s", p.redact(string(src[:valEndIndex])))
+	}
+
+	p.warn(src, "unterminated quoted value %s", p.redact(string(src[:valEndIndex])))
+
+	return strings.TrimFunc(string(src[1:valEndIndex]), isSpace), src[valEndIndex:], nil
+}
+
+// hasTrailingText reports whether the line starting at src holds anything
+// besides whitespace and a comment, an argument holds no comment
+func (p *parser) hasTrailingText(src []byte) bool {
+	endOfLine := bytes.IndexFunc(src, p.isStatementEnd)
+	if endOfLine == -1 {
+		endOfLine = len(src)
+	}
+
+	line := src[:endOfLine]
+	trimmed := bytes.TrimLeftFunc(line, isSpace)
+
+	return len(trimmed) != 0 && (p.arg || trimmed[0] != charComment || len(trimmed) == len(line))
+}
+
+// skipLine skips the whitespace and comment ending the line starting at src
+func (p *parser) skipLine(src []byte) []byte {
+	if endOfLine := bytes.IndexFunc(src, p.isStatementEnd); endOfLine != -1 {
+		return src[endOfLine:]
+	}
+
+	return nil
+}
+
+// extractHeredoc reads a KEY=<<EOF value up to the line equal to the
+// delimiter. As in shell, a quoted delimiter (<<"EOF" or <<'EOF') disables
+// variable expansion
+func (p *parser) extractHeredoc(src []byte) (value string, rest []byte, err error) {
+	endOfLine := bytes.IndexByte(src, '\n')
+	if endOfLine == -1 {
+		endOfLine = len(src)
+	}
+
+	delimiter := strings.TrimFunc(string(src[len(heredocPrefix):endOfLine]), isSpace)
+	quoted := false
+	if len(delimiter) >= 2 && strings.ContainsRune(`"'`, rune(delimiter[0])) && delimiter[len(delimiter)-1] == delimiter[0] {
+		delimiter, quoted = delimiter[1:len(delimiter)-1], true
+	}
+	if delimiter == "" {
+		return "", nil, fmt.Errorf("missing heredoc delimiter in %s", src[:endOfLine])
+	}
+
+	var lines []string
+	for body := src[endOfLine:]; len(body) > 0; {
+		// skip the newline ending the previous line
+		body = body[1:]
+
+		end := bytes.IndexByte(body, '\n')
+		if end == -1 {
+			end = len(body)
+		}
+		line := string(body[:end])
+		body = body[end:]
+
+		if line == delimiter {
+			value = strings.Join(lines, "\n")
+			if !quoted {
+				if value, err = p.expand(value); err != nil {
+					return "", nil, err
+				}
+			}
+
+			p.trace(src, "parsed %s (heredoc)", p.key)
+			return value, body, nil
+		}
+		lines = append(lines, line)
+	}
+
+	return "", nil, fmt.Errorf("unterminated heredoc %s", delimiter)
+}
+
+// extractTripleQuoted reads a KEY="""value""" value verbatim up to the
+// closing triple quote, newlines included. Variables are expanded as in
+// double quotes
+func (p *parser) extractTripleQuoted(src []byte) (value string, rest []byte, err error) {
+	p.quoted = true
+
+	body := src[len(tripleQuote):]
+	for i := 0; i+len(tripleQuote) <= len(body); i++ {
+		if !bytes.HasPrefix(body[i:], []byte(tripleQuote)) || isEscaped(body, i) {
+			continue
+		}
+
+		rest = body[i+len(tripleQuote):]
+		p.end = p.offset(rest)
+		if !p.hasTrailingText(rest) {
+			rest = p.skipLine(rest)
+		} else if p.opts.StrictQuotes {
+			return "", nil, fmt.Errorf("unexpected text after triple-quoted value %s", p.redact(string(src[:len(src)-len(rest)])))
+		}
+
+		value = string(body[:i])
+		if p.opts.ExpandInDoubleQuotes {
+			if value, err = p.expand(value); err != nil {
+				return "", nil, err
+			}
+		}
+
+		p.trace(src, "parsed %s (triple-quoted)", p.key)
+		return value, rest, nil
+	}
+
+	return "", nil, fmt.Errorf("unterminated triple-quoted value %s", p.redact(string(firstLine(src))))
 }
 
 func expandEscapes(str string) string {
-	out := escapeRegex.ReplaceAllStringFunc(str, func(match string) string {
-		c := strings.TrimPrefix(match, `\`)
-		switch c {
-		case "n":
+	return escapeRegex.ReplaceAllStringFunc(str, func(match string) string {
+		c := match[1:]
+		switch {
+		case c == "n":
 			return "\n"
-		case "r":
+		case c == "r":
 			return "\r"
-		default:
+		case c == "$":
+			// kept for variable expansion to see the escape
+			return match
+		case len(c) == 3 && c[0] == 'x':
+			b, _ := strconv.ParseUint(c[1:], 16, 8)
+			return string([]byte{byte(b)})
+		case len(c) == 3 && c[0] == '0':
+			b, _ := strconv.ParseUint(c[1:], 8, 8)
+			return string([]byte{byte(b)})
+		case c == "x" || c == "0":
+			// malformed \xNN and \0NN stay literal
 			return match
+		default:
+			return c
 		}
 	})
-	return unescapeCharsRegex.ReplaceAllString(out, "$1")
 }
 
-func expandVariables(v string, m map[string]string) string {
-	return expandVarRegex.ReplaceAllStringFunc(v, func(s string) string {
-		submatch := expandVarRegex.FindStringSubmatch(s)
+func expandVariables(v string, lookup func(name string) (string, bool)) string {
+	out, _ := expandReferences(v, lookup, false)
+	return out
+}
+
+// expandReferences replaces ${VAR} and ${VAR:-default} references in v, also
+// returning the names of the undefined references that have no default.
+// keepLiteral leaves such references unchanged instead of emptying them
+func expandReferences(v string, lookup func(name string) (string, bool), keepLiteral bool) (string, []string) {
+	var (
+		out       strings.Builder
+		undefined []string
+	)
+	for {
+		loc := expandVarRegex.FindStringSubmatchIndex(v)
+		if loc == nil {
+			out.WriteString(v)
+			return out.String(), undefined
+		}
+		out.WriteString(v[:loc[0]])
+		match, rest := v[loc[0]:loc[1]], v[loc[1]:]
+		escaped, named := loc[2] != -1, loc[8] != -1
+		name := ""
+		if named {
+			name = v[loc[8]:loc[9]]
+		}
+		v = rest
 
-		if submatch == nil {
-			return s
+		// an escaped dollar disables the whole reference, \${X} and \$X stay
+		// literal as ${X} and $X
+		if escaped {
+			out.WriteString(match[1:])
+			continue
 		}
-		if submatch[1] == "\\" || submatch[2] == "(" {
-			return submatch[0][1:]
-		} else if submatch[4] != "" {
-			return m[submatch[4]]
+		if !named {
+			out.WriteString(match)
+			continue
 		}
-		return s
-	})
+		value, ok := lookup(name)
+
+		// ${VAR:-default} takes the default verbatim up to the matching brace
+		if strings.HasPrefix(match, "${") && !strings.HasSuffix(match, "}") && strings.HasPrefix(rest, ":-") {
+			if fallback, after, closed := cutBraced(rest[len(":-"):]); closed {
+				if value == "" {
+					var missing []string
+					value, missing = expandReferences(fallback, lookup, keepLiteral)
+					undefined = append(undefined, missing...)
+				}
+				out.WriteString(value)
+				v = after
+				continue
+			}
+		}
+
+		if !ok {
+			undefined = append(undefined, name)
+			if keepLiteral {
+				value = match
+			}
+		}
+		out.WriteString(value)
+	}
+}
+
+// cutBraced splits s at the brace closing an already opened one, nested
+// braces included
+func cutBraced(s string) (inside, after string, ok bool) {
+	depth := 1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+
+	return "", s, false
 }
 
-func getStatementStart(src []byte) []byte {
-	pos := indexOfNonSpaceChar(src)
+func (p *parser) getStatementStart(src []byte) []byte {
+	pos := bytes.IndexFunc(src, func(r rune) bool {
+		return !unicode.IsSpace(r) && !(p.inline && r == charSemicolon)
+	})
 	if pos == -1 {
 		return nil
 	}
 
 	src = src[pos:]
-	if src[0] != charComment {
+	if src[0] != charComment || p.arg {
 		return src
 	}
 
 	// skip comment section
+	p.trace(src, "skipping comment")
 	pos = bytes.IndexFunc(src, isCharFunc('\n'))
 	if pos == -1 {
 		return nil
 	}
 
-	return getStatementStart(src[pos:])
+	return p.getStatementStart(src[pos:])
+}
+
+// skipBareExport skips a line holding only "export", optionally followed by a
+// comment, returning the rest of the source after it. With
+// Options.AllowExportOnly export KEY1 KEY2 lines are skipped as well
+func (p *parser) skipBareExport(src []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(src, []byte(exportPrefix)) {
+		return src, false
+	}
+
+	rest := src[len(exportPrefix):]
+	if p.hasTrailingText(rest) && !(p.opts.AllowExportOnly && p.isExportOnly(rest)) {
+		return src, false
+	}
+
+	if endOfLine := bytes.IndexFunc(rest, p.isStatementEnd); endOfLine != -1 {
+		return rest[endOfLine:], true
+	}
+
+	return nil, true
+}
+
+// isExportOnly reports whether the line starting at src, right after
+// "export", lists variable names without assigning any
+func (p *parser) isExportOnly(src []byte) bool {
+	endOfLine := bytes.IndexFunc(src, p.isStatementEnd)
+	if endOfLine == -1 {
+		endOfLine = len(src)
+	}
+
+	line := string(src[:endOfLine])
+	if line == "" || !isSpace(rune(line[0])) {
+		return false
+	}
+
+	for _, name := range strings.Fields(line) {
+		if name[0] == charComment {
+			break
+		}
+		if !posixKeyRegex.MatchString(name) {
+			return false
+		}
+	}
+
+	return true
 }
 
-func locateKeyName(src []byte) (key string, cutset []byte, err error) {
+func (p *parser) locateKeyName(src []byte) (key string, cutset []byte, err error) {
 	// trim "export" and space at beginning
 	src = bytes.TrimLeftFunc(src, isSpace)
+	p.exported = false
 	if bytes.HasPrefix(src, []byte(exportPrefix)) {
 		trimmed := bytes.TrimPrefix(src, []byte(exportPrefix))
 		if bytes.IndexFunc(trimmed, isSpace) == 0 {
 			src = bytes.TrimLeftFunc(trimmed, isSpace)
+			p.exported = true
 		}
 	}
 
+	// "quoted" names are taken verbatim
+	if quote, isQuoted := hasQuotePrefix(src); isQuoted {
+		return p.locateQuotedKeyName(src, quote)
+	}
+
 	// locate key name end and validate it in single loop
 	offset := 0
 loop:
-	for i, char := range src {
-		rchar := rune(char)
+	for i, size := 0, 0; i < len(src); i += size {
+		var rchar rune
+		rchar, size = utf8.DecodeRune(src[i:])
 		if isSpace(rchar) {
+			if p.opts.SeparatorStyle == Whitespace {
+				key = string(src[0:i])
+				offset = i + 1
+				break loop
+			}
 			continue
 		}
 
-		switch char {
+		switch rchar {
 		case '=', ':':
-			// library also supports yaml-style value declaration
+			if !p.opts.SeparatorStyle.allows(byte(rchar)) {
+				return "", nil, fmt.Errorf(
+					`unexpected separator %q for %s style near %q`,
+					string(rchar), p.opts.SeparatorStyle, p.redactLine(string(firstLine(src))))
+			}
+
+			// library also supports yaml-style value declaration, the first
+			// separator ends the name and later ones belong to the value,
+			// e.g. KEY: a=b. Either way the value goes through
+			// extractVarValue, so KEY: a # comment drops the comment too
 			key = string(src[0:i])
 			offset = i + 1
 			break loop
 		case '_':
+		case '[', ']':
+			// KEY[]=value appends to KEY
+			if p.opts.AllowAppend {
+				continue
+			}
+			fallthrough
 		default:
 			// variable name should match [A-Za-z0-9_.]
 			if unicode.IsLetter(rchar) || unicode.IsNumber(rchar) || rchar == '.' {
 				continue
 			}
 
-			return "", nil, fmt.Errorf(
-				`unexpected character %q in variable name near %q`,
-				string(char), string(src))
+			return "", nil, &InvalidKeyError{
+				Char:   rchar,
+				Offset: p.inputOffset(p.offset(src[i:])),
+				Line:   p.lineAt(src[i:]),
+				Near:   p.redactLine(string(firstLine(src))),
+			}
 		}
 	}
 
@@ -284,14 +1111,60 @@ loop:
 		return "", nil, errors.New("zero length string")
 	}
 
-	// trim whitespace
+	// hit EOF before any separator
+	if offset == 0 {
+		return "", nil, fmt.Errorf(`missing separator after variable name %s`, p.redactName(string(src)))
+	}
+
+	// trim whitespace, the default style keeps the spaces inside the name
 	key = strings.TrimRightFunc(key, unicode.IsSpace)
+	if p.opts.SeparatorStyle != EqualsOrColon && strings.ContainsFunc(key, isSpace) {
+		return "", nil, fmt.Errorf(`unexpected whitespace in variable name %s`, p.redactName(key))
+	}
+
+	name := key
+	if p.opts.AllowAppend {
+		name = strings.TrimSuffix(key, appendSuffix)
+		if strings.ContainsAny(name, "[]") {
+			return "", nil, fmt.Errorf(`unexpected brackets in variable name %q, only trailing [] is allowed`, key)
+		}
+	}
+	if p.opts.POSIXKeys && !posixKeyRegex.MatchString(name) {
+		return "", nil, fmt.Errorf(`variable name %q does not match POSIX [a-zA-Z_][a-zA-Z0-9_]*`, name)
+	}
 	cutset = bytes.TrimLeftFunc(src[offset:], isSpace)
 	return key, cutset, nil
 }
 
-func indexOfNonSpaceChar(src []byte) int {
-	return bytes.IndexFunc(src, func(r rune) bool { return !unicode.IsSpace(r) })
+// locateQuotedKeyName reads a variable name enclosed in quote, keeping case
+// and any characters but line ends
+func (p *parser) locateQuotedKeyName(src []byte, quote byte) (key string, cutset []byte, err error) {
+	end := bytes.IndexFunc(src[1:], func(r rune) bool { return r == rune(quote) || isLineEnd(r) })
+	if end == -1 || src[1+end] != quote {
+		return "", nil, fmt.Errorf(`unterminated quoted variable name %s`, p.redactName(string(firstLine(src))))
+	}
+
+	key, rest := string(src[1:1+end]), src[2+end:]
+	if key == "" {
+		return "", nil, errors.New("zero length string")
+	}
+
+	if p.opts.SeparatorStyle == Whitespace {
+		if len(rest) == 0 || !isSpace(rune(rest[0])) {
+			return "", nil, fmt.Errorf(`missing separator after variable name %s`, p.redactName(key))
+		}
+	} else {
+		rest = bytes.TrimLeftFunc(rest, isSpace)
+		if len(rest) == 0 || !p.opts.SeparatorStyle.allows(rest[0]) {
+			return "", nil, fmt.Errorf(`missing separator after variable name %s`, p.redactName(key))
+		}
+	}
+
+	if p.opts.POSIXKeys && !posixKeyRegex.MatchString(key) {
+		return "", nil, fmt.Errorf(`variable name %q does not match POSIX [a-zA-Z_][a-zA-Z0-9_]*`, key)
+	}
+
+	return key, bytes.TrimLeftFunc(rest[1:], isSpace), nil
 }
 
 func isSpace(r rune) bool {
@@ -302,6 +1175,11 @@ func isCharFunc(char rune) func(rune) bool {
 	return func(v rune) bool { return v == char }
 }
 
+// isStatementEnd reports whether r ends an unquoted value
+func (p *parser) isStatementEnd(r rune) bool {
+	return isLineEnd(r) || p.inline && r == charSemicolon
+}
+
 func isLineEnd(r rune) bool {
 	return slices.Contains([]rune{'\n', '\r'}, r)
 }
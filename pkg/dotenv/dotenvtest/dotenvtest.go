@@ -0,0 +1,34 @@
+// Package dotenvtest provides helpers for tests depending on env files
+package dotenvtest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/KoNekoD/dotenv/pkg/dotenv"
+)
+
+// LoadEnvT loads env files by path like dotenv.LoadEnv for the duration of a
+// test, the environment and the working directory are restored by tb.Cleanup
+func LoadEnvT(tb testing.TB, path ...string) {
+	tb.Helper()
+	LoadEnvWith(tb, dotenv.NewLoader(dotenv.DefaultOptions()), path...)
+}
+
+// LoadEnvWith loads env files by path with l like LoadEnvT. A load error
+// fails the test
+func LoadEnvWith(tb testing.TB, l *dotenv.Loader, path ...string) {
+	tb.Helper()
+
+	// LoadEnv changes to the module root
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = os.Chdir(wd) })
+
+	tb.Cleanup(dotenv.Snapshot())
+	if err := l.LoadEnv(path...); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+package dotenvtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEnvT(t *testing.T) {
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("DOTENVTEST_KEPT", "kept")
+	t.Setenv("DOTENVTEST_ADDED", "")
+	_ = os.Unsetenv("DOTENVTEST_ADDED")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("DOTENVTEST_ADDED=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("load", func(t *testing.T) {
+		LoadEnvT(t, filepath.Join(dir, ".env"))
+
+		if got := os.Getenv("DOTENVTEST_ADDED"); got != "1" {
+			t.Errorf("DOTENVTEST_ADDED = %q, want 1", got)
+		}
+	})
+
+	if got, ok := os.LookupEnv("DOTENVTEST_ADDED"); ok {
+		t.Errorf("DOTENVTEST_ADDED = %q after the test, want unset", got)
+	}
+	if got := os.Getenv("DOTENVTEST_KEPT"); got != "kept" {
+		t.Errorf("DOTENVTEST_KEPT = %q after the test, want kept", got)
+	}
+	if after, err := os.Getwd(); err != nil || after != wd {
+		t.Errorf("working directory %s after the test, want %s (%v)", after, wd, err)
+	}
+}
@@ -0,0 +1,20 @@
+package dotenvtest_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/KoNekoD/dotenv/pkg/dotenv/dotenvtest"
+)
+
+func ExampleLoadEnvT() {
+	// t is the *testing.T of the test function
+	var t *testing.T
+
+	dotenvtest.LoadEnvT(t, ".env.test")
+
+	// the variables of .env.test are set until the test ends
+	if os.Getenv("DATABASE_URL") == "" {
+		t.Fatal("DATABASE_URL is not set")
+	}
+}
@@ -0,0 +1,165 @@
+package dotenv
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// GetSlice splits the environment variable key by sep, trimming spaces
+// around the elements and dropping empty ones
+func GetSlice(key, sep string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// Diff compares filename with the environment, returning the keys of the
+// file whose value differs from the environment as {fileValue, envValue}.
+// Variables missing from the environment are reported with an empty envValue
+func Diff(filename string) (map[string][2]string, error) {
+	return NewLoader(DefaultOptions()).Diff(filename)
+}
+
+// Diff compares filename with the environment, returning the keys of the
+// file whose value differs from the environment as {fileValue, envValue}.
+// Variables missing from the environment are reported with an empty envValue
+func (l *Loader) Diff(filename string) (map[string][2]string, error) {
+	envMap, err := l.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][2]string)
+	for k, fileValue := range envMap {
+		if envValue, ok := os.LookupEnv(k); !ok || envValue != fileValue {
+			out[k] = [2]string{fileValue, envValue}
+		}
+	}
+
+	return out, nil
+}
+
+// OverridePlan merges env files by path and returns the environment
+// variables they would change if they overrode the environment, as
+// {envValue, fileValue}
+func OverridePlan(path ...string) (map[string][2]string, error) {
+	return NewLoader(DefaultOptions()).OverridePlan(path...)
+}
+
+// OverridePlan merges env files by path and returns the environment
+// variables they would change if they overrode the environment, as
+// {envValue, fileValue}. Variables missing from the environment are not
+// reported
+func (l *Loader) OverridePlan(path ...string) (map[string][2]string, error) {
+	envMap, err := l.readFiles(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][2]string)
+	for k, fileValue := range envMap {
+		if envValue, ok := os.LookupEnv(k); ok && envValue != fileValue {
+			out[k] = [2]string{envValue, fileValue}
+		}
+	}
+
+	return out, nil
+}
+
+// EnvSlice merges env files by path over the environment without touching
+// it, returning sorted KEY=VALUE entries suitable for exec.Cmd.Env
+func EnvSlice(path ...string) ([]string, error) {
+	return NewLoader(DefaultOptions()).EnvSlice(path...)
+}
+
+// EnvSlice merges env files by path over the environment without touching
+// it, returning sorted KEY=VALUE entries suitable for exec.Cmd.Env. Real
+// environment variables win over env files
+func (l *Loader) EnvSlice(path ...string) ([]string, error) {
+	envMap, err := l.readFiles(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := environ()
+	existing := envTarget{loaded: l.loaded}.existing()
+	for k, v := range envMap {
+		if _, ok := existing[k]; !ok {
+			merged[k] = v
+		}
+	}
+
+	out := make([]string, 0, len(merged))
+	for _, k := range sortedKeys(merged) {
+		out = append(out, k+"="+merged[k])
+	}
+
+	return out, nil
+}
+
+// ApplyToCmd sets cmd.Env to env files by path merged over the environment,
+// see EnvSlice
+func ApplyToCmd(cmd *exec.Cmd, path ...string) error {
+	return NewLoader(DefaultOptions()).ApplyToCmd(cmd, path...)
+}
+
+// ApplyToCmd sets cmd.Env to env files by path merged over the environment,
+// neither the environment nor the working directory of the current process
+// is changed
+func (l *Loader) ApplyToCmd(cmd *exec.Cmd, path ...string) error {
+	env, err := l.EnvSlice(path...)
+	if err != nil {
+		return err
+	}
+	cmd.Env = env
+
+	return nil
+}
+
+// CheckReferences reports the ${VAR} references in path that resolve neither
+// from the file itself, nor from extra, nor from the environment
+func CheckReferences(path string, extra map[string]string) ([]string, error) {
+	return NewLoader(DefaultOptions()).CheckReferences(path, extra)
+}
+
+// CheckReferences reports the ${VAR} references in path that resolve neither
+// from the file itself, nor from extra, nor from the environment. Each name
+// is reported once, in order of first use
+func (l *Loader) CheckReferences(path string, extra map[string]string) ([]string, error) {
+	checker := NewLoader(l.opts)
+	checker.opts.ExpandVars = extra
+	checker.opts.ExpandSources = []Source{SourceFile, SourceVars, SourceOS}
+	checker.opts.OnUndefined = UndefinedEmpty
+
+	src, err := checker.readSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := checker.newParser(path, src)
+	for {
+		_, _, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	var unresolved []string
+	for _, name := range p.undefined {
+		if !slices.Contains(unresolved, name) {
+			unresolved = append(unresolved, name)
+		}
+	}
+
+	return unresolved, nil
+}
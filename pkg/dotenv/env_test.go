@@ -0,0 +1,162 @@
+package dotenv
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetSlice(t *testing.T) {
+	t.Setenv("SLICE_SERVERS", " host1 , host2,,host3 ")
+	if got, want := GetSlice("SLICE_SERVERS", ","), []string{"host1", "host2", "host3"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	unsetenv(t, "SLICE_MISSING")
+	if got := GetSlice("SLICE_MISSING", ","); len(got) != 0 {
+		t.Errorf("got %q, want none", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Setenv("DIFF_SAME", "1")
+	t.Setenv("DIFF_CHANGED", "env")
+	unsetenv(t, "DIFF_MISSING")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "DIFF_SAME=1\nDIFF_CHANGED=file\nDIFF_MISSING=new\n"})
+
+	got, err := Diff(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]string{
+		"DIFF_CHANGED": {"file", "env"},
+		"DIFF_MISSING": {"new", ""},
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOverridePlan(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	t.Setenv("PLAN_SAME", "1")
+	t.Setenv("PLAN_CONFLICT", "env")
+	unsetenv(t, "PLAN_NEW")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":      "PLAN_SAME=1\nPLAN_CONFLICT=base\nPLAN_NEW=new\n",
+		".env.test": "PLAN_CONFLICT=test\n",
+	})
+
+	got, err := OverridePlan(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// equal and missing variables would not change
+	if want := map[string][2]string{"PLAN_CONFLICT": {"env", "test"}}; !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := os.Getenv("PLAN_CONFLICT"); got != "env" {
+		t.Errorf("PLAN_CONFLICT = %q, want the environment untouched", got)
+	}
+}
+
+func TestEnvSlice(t *testing.T) {
+	t.Setenv("SLICE_OS", "os")
+	unsetenv(t, EnvKey, "SLICE_FILE", "SLICE_LOCAL", "SLICE_DEV")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":           "SLICE_OS=file\nSLICE_FILE=file\nSLICE_LOCAL=file\n",
+		".env.local":     "SLICE_LOCAL=local\n",
+		".env.dev.local": "SLICE_DEV=dev\n",
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := EnvSlice(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSorted(got) {
+		t.Error("got unsorted entries")
+	}
+
+	// real variables win, later tiers override the former and an unset
+	// APP_ENV selects the dev tiers
+	for _, want := range []string{"SLICE_OS=os", "SLICE_FILE=file", "SLICE_LOCAL=local", "SLICE_DEV=dev"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if slices.ContainsFunc(got, func(entry string) bool { return strings.HasPrefix(entry, EnvKey+"=") }) {
+		t.Errorf("got %s, want it unset", EnvKey)
+	}
+
+	// neither the environment nor the working directory is touched
+	for _, k := range []string{EnvKey, "SLICE_FILE", "SLICE_LOCAL", "SLICE_DEV"} {
+		if v, ok := os.LookupEnv(k); ok {
+			t.Errorf("%s = %q, want unset", k, v)
+		}
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
+
+func TestApplyToCmd(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to run")
+	}
+	t.Setenv("CMD_OS", "os")
+	unsetenv(t, EnvKey, "CMD_FILE")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "CMD_FILE=file\nCMD_OS=file\n"})
+
+	cmd := exec.Command(sh, "-c", `printf '%s %s %s' "$CMD_FILE" "$CMD_OS" "${APP_ENV-unset}"`)
+	if err := ApplyToCmd(cmd, filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "file os unset"; string(out) != want {
+		t.Errorf("child printed %q, want %q", out, want)
+	}
+
+	if v, ok := os.LookupEnv("CMD_FILE"); ok {
+		t.Errorf("CMD_FILE = %q, want the current process untouched", v)
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	t.Setenv("REF_OS", "1")
+	unsetenv(t, "REF_MISSING", "REF_ALSO_MISSING", "REF_DEFAULTED")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "REF_FILE=1\n" +
+		"A=${REF_FILE}${REF_EXTRA}${REF_OS}\n" +
+		"B=${REF_MISSING} $REF_ALSO_MISSING ${REF_MISSING}\n" +
+		"C=${REF_DEFAULTED:-fallback}\n"})
+
+	got, err := CheckReferences(filepath.Join(dir, ".env"), map[string]string{"REF_EXTRA": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"REF_MISSING", "REF_ALSO_MISSING"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
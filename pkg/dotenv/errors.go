@@ -0,0 +1,60 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// InvalidKeyError reports an unexpected character in a variable name
+type InvalidKeyError struct {
+	Char rune
+	// Offset is the byte offset of Char in the parsed input, \r\n line ends
+	// included, Line is its 1-based line number
+	Offset int
+	Line   int
+	// Near is the rest of the line starting at the variable name
+	Near string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return fmt.Sprintf("line %d: unexpected character %q in variable name near %q", e.Line, string(e.Char), e.Near)
+}
+
+// FieldConversionError reports a value that cannot be stored into the struct
+// field bound to its key
+type FieldConversionError struct {
+	Key   string
+	Value string
+	Type  reflect.Type
+	Err   error
+}
+
+func (e *FieldConversionError) Error() string {
+	return fmt.Sprintf("cannot convert %s=%q to %s: %v", e.Key, e.Value, e.Type, e.Err)
+}
+
+func (e *FieldConversionError) Unwrap() error {
+	return e.Err
+}
+
+// redactedError hides the value err is about from its message, still
+// unwrapping to it
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	// strconv errors quote the value, keep the reason only
+	var numErr *strconv.NumError
+	if errors.As(e.err, &numErr) {
+		return "strconv." + numErr.Func + ": " + numErr.Err.Error()
+	}
+
+	return "invalid value"
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
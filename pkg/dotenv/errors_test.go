@@ -0,0 +1,33 @@
+package dotenv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInvalidKeyErrorPosition(t *testing.T) {
+	for _, tt := range []struct {
+		src          string
+		offset, line int
+	}{
+		{"C-D=3\n", 1, 1},
+		{"A=1\nB=2\nC-D=3\n", 9, 3},
+		{"A=1\r\nB=2\r\nC-D=3\r\n", 11, 3},
+		{"A=1\r\n\r\n# c\r\n  C-D=3", 15, 4},
+		{"A=\"multi\r\nline\"\r\nC-D=3", 18, 3},
+	} {
+		err := parseErr(t, DefaultOptions(), tt.src)
+
+		var keyErr *InvalidKeyError
+		if !errors.As(err, &keyErr) {
+			t.Fatalf("%q: got %v, want an InvalidKeyError", tt.src, err)
+		}
+		if keyErr.Char != '-' || keyErr.Offset != tt.offset || keyErr.Line != tt.line {
+			t.Errorf("%q: got %q at offset %d line %d, want '-' at offset %d line %d",
+				tt.src, keyErr.Char, keyErr.Offset, keyErr.Line, tt.offset, tt.line)
+		}
+		if tt.src[keyErr.Offset] != '-' {
+			t.Errorf("%q: offset %d points at %q", tt.src, keyErr.Offset, tt.src[keyErr.Offset])
+		}
+	}
+}
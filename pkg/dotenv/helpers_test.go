@@ -0,0 +1,91 @@
+package dotenv
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unsetenv unsets keys for the duration of the test
+func unsetenv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, k := range keys {
+		t.Setenv(k, "")
+		_ = os.Unsetenv(k)
+	}
+}
+
+// keepWd restores the working directory once the test ends, loaders change
+// it to the module root
+func keepWd(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+// writeFiles creates files with the given contents in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// mustParse parses src with opts, failing the test on error
+func mustParse(t *testing.T, opts Options, src string) map[string]string {
+	t.Helper()
+	envMap, err := NewLoader(opts).Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+
+	return envMap
+}
+
+// parseErr parses src with opts, failing the test unless it errors
+func parseErr(t *testing.T, opts Options, src string) error {
+	t.Helper()
+	envMap, err := NewLoader(opts).Parse(strings.NewReader(src))
+	if err == nil {
+		t.Fatalf("parse %q: expected an error, got %v", src, envMap)
+	}
+
+	return err
+}
+
+// assertMap fails the test unless got equals want
+func assertMap(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// loadMap writes files to a temporary directory and merges its .env tiers
+// with opts into a map, with APP_ENV=test
+func loadMap(t *testing.T, opts Options, files map[string]string) map[string]string {
+	t.Helper()
+	t.Setenv(EnvKey, "test")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	out := make(mapTarget)
+	l := NewLoader(opts)
+	if err := l.applyFiles(l.envFiles(filepath.Join(dir, ".env")), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
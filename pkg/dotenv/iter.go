@@ -0,0 +1,48 @@
+package dotenv
+
+import (
+	"io"
+	"iter"
+)
+
+// Entries returns an iterator over key/value pairs read from r, in file
+// order, using the default options. The returned function reports the error
+// that stopped the iteration, if any
+func Entries(r io.Reader) (iter.Seq2[string, string], func() error) {
+	return NewLoader(DefaultOptions()).Entries(r)
+}
+
+// Entries returns an iterator over key/value pairs read from r, in file
+// order. Statements are parsed lazily as the iteration advances. The
+// returned function reports the error that stopped the iteration, if any
+func (l *Loader) Entries(r io.Reader) (iter.Seq2[string, string], func() error) {
+	var err error
+
+	seq := func(yield func(string, string) bool) {
+		src, readErr := io.ReadAll(r)
+		if readErr != nil {
+			err = readErr
+			return
+		}
+
+		p := l.newParser("", src)
+		for {
+			key, value, ok, nextErr := p.next()
+			if nextErr != nil {
+				err = nextErr
+				return
+			}
+			if !ok {
+				return
+			}
+			if l.isUnset(value) {
+				continue
+			}
+			if !yield(key, value) {
+				return
+			}
+		}
+	}
+
+	return seq, func() error { return err }
+}
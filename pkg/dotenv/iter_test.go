@@ -0,0 +1,56 @@
+package dotenv
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEntries(t *testing.T) {
+	t.Run("range", func(t *testing.T) {
+		seq, errFn := Entries(strings.NewReader("A=1\n# comment\nB='two'\nA=3\n"))
+
+		var got []string
+		for k, v := range seq {
+			got = append(got, k+"="+v)
+		}
+		if want := []string{"A=1", "B=two", "A=3"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if err := errFn(); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("early break", func(t *testing.T) {
+		// the statement after the break is never parsed
+		seq, errFn := Entries(strings.NewReader("A=1\nB=\"unterminated\n"))
+
+		var got []string
+		for k := range seq {
+			got = append(got, k)
+			break
+		}
+		if want := []string{"A"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if err := errFn(); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		seq, errFn := Entries(strings.NewReader("A=1\nB=\"unterminated\nC=3\n"))
+
+		var got []string
+		for k := range seq {
+			got = append(got, k)
+		}
+		if want := []string{"A"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if err := errFn(); err == nil || !strings.Contains(err.Error(), "unterminated") {
+			t.Errorf("got error %v, want an unterminated quote one", err)
+		}
+	})
+}
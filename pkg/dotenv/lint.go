@@ -0,0 +1,73 @@
+package dotenv
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Lint checks filename using the default options, see Loader.Lint
+func Lint(filename string) ([]Warning, error) {
+	return NewLoader(DefaultOptions()).Lint(filename)
+}
+
+// Lint parses filename without touching the environment and reports the
+// problems found besides parse errors: the warnings of the parse and every
+// group of keys differing only by case, which collide on case-insensitive
+// platforms
+func (l *Loader) Lint(filename string) ([]Warning, error) {
+	src, err := l.readSource(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(l.warnings)
+	p := l.newParser(filename, src)
+
+	type spelling struct {
+		key  string
+		line int
+	}
+	var (
+		groups = make(map[string][]spelling)
+		order  []string
+	)
+	for {
+		key, _, ok, err := p.next()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if !ok {
+			break
+		}
+
+		folded := strings.ToUpper(key)
+		group := groups[folded]
+		if group == nil {
+			order = append(order, folded)
+		}
+		if !slices.ContainsFunc(group, func(s spelling) bool { return s.key == key }) {
+			groups[folded] = append(group, spelling{key: key, line: p.lineAt(p.src[p.start:])})
+		}
+	}
+
+	out := slices.Clone(l.warnings[n:])
+	for _, folded := range order {
+		group := groups[folded]
+		if len(group) < 2 {
+			continue
+		}
+
+		keys := make([]string, len(group))
+		for i, s := range group {
+			keys[i] = s.key
+		}
+		out = append(out, Warning{
+			File: filename,
+			Line: group[1].line,
+			Msg:  fmt.Sprintf("keys differ only by case: %s", strings.Join(keys, ", ")),
+		})
+	}
+
+	return out, nil
+}
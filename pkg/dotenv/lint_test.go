@@ -0,0 +1,32 @@
+package dotenv
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "db_host=a\nPORT=1\nDB_HOST=b\nPORT=2\nDb_Host=c\nport=3\nOTHER=x\n"})
+	filename := filepath.Join(dir, ".env")
+
+	got, err := Lint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reassigning the same spelling is no collision
+	want := []Warning{
+		{File: filename, Line: 3, Msg: "keys differ only by case: db_host, DB_HOST, Db_Host"},
+		{File: filename, Line: 6, Msg: "keys differ only by case: PORT, port"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	writeFiles(t, dir, map[string]string{".env": "A=1\nB=2\nA=3\n"})
+	if got, err := Lint(filename); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v, want no warnings", got, err)
+	}
+}
@@ -0,0 +1,676 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KoNekoD/rootpath/pkg/rootpath"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Loader loads and writes env files according to its Options
+type Loader struct {
+	opts Options
+
+	// loaded holds the keys set by the loader itself, these are not treated
+	// as real environment variables on subsequent loads
+	loaded map[string]struct{}
+	// mtimes holds modification times of the files seen by ReloadIfChanged
+	mtimes map[string]time.Time
+	// warnings holds the non-fatal problems found so far
+	warnings []Warning
+	// frozen holds the keys later loads must not change
+	frozen map[string]struct{}
+	// open opens the env files to read, os.Open unless replaced by tests
+	open func(name string) (fs.File, error)
+}
+
+// NewLoader creates a Loader with the given options
+func NewLoader(opts Options) *Loader {
+	return &Loader{opts: opts, loaded: make(map[string]struct{}), frozen: make(map[string]struct{}), open: openFile}
+}
+
+func openFile(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// Freeze protects keys from later loads of the loader, which skip them even
+// when their value was set by an earlier load
+func (l *Loader) Freeze(keys ...string) {
+	for _, k := range keys {
+		l.frozen[k] = struct{}{}
+	}
+}
+
+// Warnings returns the non-fatal problems found by the loader so far
+func (l *Loader) Warnings() []Warning {
+	return slices.Clone(l.warnings)
+}
+
+// LoadEnv loads env files by path, in order of precedence
+func (l *Loader) LoadEnv(path ...string) error {
+	rootpath.MustChdir()
+
+	return l.loadFiles(l.envFiles(basePath(path)), nil, nil)
+}
+
+// LoadIfSet loads env files by path like LoadEnv when the sentinel
+// environment variable is non-empty, and does nothing otherwise
+func (l *Loader) LoadIfSet(sentinel string, path ...string) error {
+	if os.Getenv(sentinel) == "" {
+		return nil
+	}
+
+	return l.LoadEnv(path...)
+}
+
+// LoadEnvKeys loads env files by path like LoadEnv, but only applies the
+// listed keys
+func (l *Loader) LoadEnvKeys(keys []string, path ...string) error {
+	rootpath.MustChdir()
+
+	if keys == nil {
+		keys = []string{}
+	}
+
+	return l.loadFiles(l.envFiles(basePath(path)), keys, nil)
+}
+
+// LoadRequired loads env files by path like LoadEnv, then fails listing
+// every required key that is unset or empty in the environment
+func (l *Loader) LoadRequired(required []string, path ...string) error {
+	if err := l.LoadEnv(path...); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range required {
+		if os.Getenv(key) == "" {
+			errs = append(errs, fmt.Errorf("required variable %s is not set", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadEnvDir loads env files by path relative to dir, in order of
+// precedence, without changing the working directory. Absolute names are
+// used as they are
+func (l *Loader) LoadEnvDir(dir string, path ...string) error {
+	return l.loadFiles(l.inDir(dir, l.envFiles(basePath(path))), nil, nil)
+}
+
+// LoadChain loads env files for each base path in turn, every base following
+// the LoadEnv precedence scheme and overriding the bases before it
+func (l *Loader) LoadChain(bases ...string) error {
+	rootpath.MustChdir()
+
+	files := l.defaultsFiles()
+	for _, base := range bases {
+		files = append(files, l.baseFiles(base)...)
+	}
+	files = append(files, l.secretFiles()...)
+
+	return l.loadFiles(files, nil, nil)
+}
+
+// LoadGlob loads the env files matching pattern in sorted order, later files
+// overriding the former. No match loads nothing
+func (l *Loader) LoadGlob(pattern string) error {
+	rootpath.MustChdir()
+
+	names, err := filepath.Glob(l.resolvePath(pattern))
+	if err != nil {
+		return err
+	}
+
+	files := make([]envFile, 0, len(names))
+	for _, name := range names {
+		files = append(files, envFile{name: name})
+	}
+
+	return l.loadFiles(files, nil, nil)
+}
+
+// LoadFiles loads the listed env files in order, later files overriding the
+// former. Missing files are skipped unless Options.StrictFiles is set
+func (l *Loader) LoadFiles(paths ...string) error {
+	rootpath.MustChdir()
+
+	files := make([]envFile, 0, len(paths))
+	for _, name := range paths {
+		files = append(files, envFile{name: name, required: l.opts.StrictFiles})
+	}
+
+	return l.loadFiles(files, nil, nil)
+}
+
+// LoadManifest loads the env files listed in the manifest file one per line
+// like LoadFiles, blank lines and # comments are ignored. Relative paths are
+// resolved against the directory of the manifest
+func (l *Loader) LoadManifest(manifestPath string) error {
+	rootpath.MustChdir()
+
+	src, err := l.readSource(l.resolvePath(manifestPath))
+	if err != nil {
+		return err
+	}
+
+	var files []envFile
+	for _, line := range strings.Split(string(src), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || name[0] == charComment {
+			continue
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(manifestPath), name)
+		}
+		files = append(files, envFile{name: name, required: l.opts.StrictFiles})
+	}
+
+	return l.loadFiles(files, nil, nil)
+}
+
+// LoadEnvRaw loads env files by path like LoadEnv, also returning the content
+// of every file read by file name. Gzip-compressed files are returned
+// compressed, as stored on disk
+func (l *Loader) LoadEnvRaw(path ...string) (map[string][]byte, error) {
+	rootpath.MustChdir()
+
+	raw := make(map[string][]byte)
+	if err := l.loadFiles(l.envFiles(basePath(path)), nil, raw); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// LoadEnvTimeout loads env files by path like LoadEnv, failing when reading
+// them takes longer than d. The environment is left untouched on timeout,
+// while the reads still pending finish in the background
+func (l *Loader) LoadEnvTimeout(d time.Duration, path ...string) error {
+	rootpath.MustChdir()
+
+	files := l.envFiles(basePath(path))
+	dst := envTarget{loaded: l.loaded}
+	deferred := newDeferredTarget(dst)
+
+	// the background reader must not share state the caller keeps using
+	reader := NewLoader(l.opts)
+	reader.frozen, reader.open = maps.Clone(l.frozen), l.open
+
+	done := make(chan error, 1)
+	go func() { done <- reader.applyFiles(files, nil, deferred, nil) }()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		l.warnings = append(l.warnings, reader.warnings...)
+		if err != nil {
+			return err
+		}
+		deferred.replay(dst)
+		setAppEnv()
+
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("reading env files timed out after %s: %w", d, os.ErrDeadlineExceeded)
+	}
+}
+
+// LoadIntoSyncMap loads env files by path like LoadEnv, but stores the values
+// into m instead of the environment. Keys already stored in m are kept
+func (l *Loader) LoadIntoSyncMap(m *sync.Map, path ...string) error {
+	rootpath.MustChdir()
+
+	return l.applyFiles(l.envFiles(basePath(path)), nil, syncMapTarget{m: m}, nil)
+}
+
+// ReloadIfChanged loads env files by path like LoadEnv, but only when any of
+// them was created, removed or modified since the previous successful load.
+// All the tiers are loaded again then, not only the changed one. The first
+// call always loads
+func (l *Loader) ReloadIfChanged(path ...string) (changed bool, err error) {
+	rootpath.MustChdir()
+
+	files := l.envFiles(basePath(path))
+
+	mtimes, err := l.modTimes(files)
+	if err != nil {
+		return false, err
+	}
+	if l.mtimes != nil && maps.EqualFunc(l.mtimes, mtimes, time.Time.Equal) {
+		return false, nil
+	}
+
+	if err = l.loadFiles(files, nil, nil); err != nil {
+		return false, err
+	}
+
+	// the load may have chosen another environment, naming other tiers
+	if mtimes, err = l.modTimes(files); err != nil {
+		return false, err
+	}
+	l.mtimes = mtimes
+
+	return true, nil
+}
+
+// modTimes returns the modification times of the existing files named for
+// the current environment
+func (l *Loader) modTimes(files []envFile) (map[string]time.Time, error) {
+	mtimes := make(map[string]time.Time, len(files))
+	for _, name := range l.tierNames(files) {
+		info, err := os.Stat(l.resolvePath(name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		} else if err == nil {
+			mtimes[name] = info.ModTime()
+		}
+	}
+
+	return mtimes, nil
+}
+
+// loadFiles applies files to the environment in order, keys limits the
+// applied keys unless nil. The content of the files read is stored into raw
+// unless nil
+func (l *Loader) loadFiles(files []envFile, keys []string, raw map[string][]byte) error {
+	dst := envTarget{loaded: l.loaded}
+	if !l.opts.Transactional {
+		if err := l.applyFiles(files, keys, dst, raw); err != nil {
+			return err
+		}
+		setAppEnv()
+
+		return nil
+	}
+
+	deferred := newDeferredTarget(dst)
+	if err := l.applyFiles(files, keys, deferred, raw); err != nil {
+		return err
+	}
+	deferred.replay(dst)
+	setAppEnv()
+
+	return nil
+}
+
+// applyFiles applies files to dst in order, keys limits the applied keys
+// unless nil. The content of the files read, as stored on disk, is stored into
+// raw unless nil
+func (l *Loader) applyFiles(files []envFile, keys []string, dst target, raw map[string][]byte) error {
+	originalVarNames := dst.existing()
+	lists := make(map[string]string)
+
+	for _, file := range files {
+		// the files applied so far may choose the environment
+		env := l.tierEnv(dst)
+		if file.local && l.opts.LoadLocal != nil && !l.opts.LoadLocal(env) {
+			continue
+		}
+		name := file.tierName(env)
+
+		individualEnvMap, src, individualErr := l.readEnvFile(file, name)
+		if individualErr != nil && l.opts.OnFileError != nil {
+			if individualErr = l.opts.OnFileError(name, individualErr); individualErr == nil {
+				continue
+			}
+		}
+		if individualErr != nil {
+			return individualErr
+		}
+		if raw != nil && src != nil {
+			raw[name] = src
+		}
+		l.apply(individualEnvMap, keys, originalVarNames, dst, lists)
+	}
+
+	return nil
+}
+
+// apply applies a parsed env map to dst, skipping the variables dst had
+// before the load. lists holds the values of Options.ListMergeKeys applied so
+// far, new values are merged into them unless lists is nil
+func (l *Loader) apply(envMap map[string]string, keys []string, originalVarNames map[string]struct{}, dst target, lists map[string]string) {
+	for k, v := range l.rename(envMap) {
+		if keys != nil && !slices.Contains(keys, k) {
+			continue
+		}
+		if _, ok := originalVarNames[k]; ok {
+			continue
+		}
+		if _, ok := l.frozen[k]; ok {
+			continue
+		}
+		if slices.Contains(l.opts.Protect, k) {
+			continue
+		}
+
+		v, ok := l.transform(k, v)
+		if !ok {
+			continue
+		}
+
+		if l.isUnset(v) {
+			delete(lists, k)
+			dst.unset(k)
+			continue
+		}
+		if lists != nil && slices.Contains(l.opts.ListMergeKeys, k) {
+			v = mergeList(lists[k], v)
+			lists[k] = v
+		}
+		dst.set(k, v)
+	}
+}
+
+// mergeList returns the union of the comma separated elements of prev and v,
+// in order of first appearance
+func mergeList(prev, v string) string {
+	var out []string
+	for _, item := range strings.Split(prev+listSeparator+v, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" && !slices.Contains(out, item) {
+			out = append(out, item)
+		}
+	}
+
+	return strings.Join(out, listSeparator)
+}
+
+// readFiles merges env files by path, in order of precedence, without
+// touching the environment or the working directory
+func (l *Loader) readFiles(path ...string) (map[string]string, error) {
+	out := make(mapTarget)
+	if err := l.applyFiles(l.inRoot(l.envFiles(basePath(path))), nil, out, nil); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// inRoot resolves the relative names of files against the module root, where
+// the loads change the working directory to. The working directory itself
+// stays untouched and is used when there is no module root
+func (l *Loader) inRoot(files []envFile) []envFile {
+	root, err := rootpath.Dir()
+	if err != nil {
+		return files
+	}
+
+	return l.inDir(root, files)
+}
+
+// inDir resolves the relative names of files against dir
+func (l *Loader) inDir(dir string, files []envFile) []envFile {
+	for i, file := range files {
+		if filepath.IsAbs(file.name) || l.opts.ExpandPaths && strings.HasPrefix(file.name, "~") {
+			continue
+		}
+		files[i].name = filepath.Join(dir, file.name)
+	}
+
+	return files
+}
+
+// rename applies Options.Rename and Options.RemapDeprecated to parsed keys, a
+// key explicitly assigned in the same file wins over one renamed to it
+func (l *Loader) rename(envMap map[string]string) map[string]string {
+	remap := l.opts.RemapDeprecated && len(l.opts.Deprecated) > 0
+	if len(l.opts.Rename) == 0 && !remap {
+		return envMap
+	}
+
+	out := make(map[string]string, len(envMap))
+	for k, v := range envMap {
+		if newKey, ok := l.opts.Rename[k]; ok {
+			if _, exists := envMap[newKey]; !exists {
+				out[newKey] = v
+			}
+			continue
+		}
+		out[k] = v
+
+		// deprecated keys stay loaded under their old name as well
+		if newKey, ok := l.opts.Deprecated[k]; ok && remap {
+			if _, exists := envMap[newKey]; !exists {
+				out[newKey] = v
+			}
+		}
+	}
+
+	return out
+}
+
+// transform applies Options.Transform to a parsed value, ok is false when the
+// key should be dropped
+func (l *Loader) transform(key, value string) (string, bool) {
+	if l.opts.Transform == nil {
+		return value, true
+	}
+
+	return l.opts.Transform(key, value)
+}
+
+// ParseInline parses statements separated by newlines or semicolons, e.g.
+// "A=1;B='two'", with the same quoting and expansion rules as env files
+func (l *Loader) ParseInline(s string) (map[string]string, error) {
+	p := l.newParser("", []byte(s))
+	p.inline = true
+	for {
+		_, _, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return l.omitUnset(p.vars), nil
+		}
+	}
+}
+
+// omitUnset drops the keys parsed values of which mean unset
+func (l *Loader) omitUnset(envMap map[string]string) map[string]string {
+	for k, v := range envMap {
+		if l.isUnset(v) {
+			delete(envMap, k)
+		}
+	}
+
+	return envMap
+}
+
+// isUnset reports whether a parsed value means the key should be unset
+func (l *Loader) isUnset(value string) bool {
+	return l.opts.EmptyMeansUnset && value == ""
+}
+
+func basePath(path []string) string {
+	if len(path) != 1 {
+		return ".env"
+	}
+
+	return path[0]
+}
+
+// envFile is a file of the precedence scheme
+type envFile struct {
+	name string
+	// required files must exist, the others are optional tiers
+	required bool
+	// secret files must not be accessible by group or others
+	secret bool
+	// env tiers are named name.<env>, the environment being resolved when
+	// the tier is reached
+	env bool
+	// local tiers are skipped when Options.LoadLocal excludes the
+	// environment, local env tiers are named name.<env>.local
+	local bool
+}
+
+// tierName returns the name of file for the environment env
+func (f envFile) tierName(env string) string {
+	if !f.env {
+		return f.name
+	}
+	if f.local {
+		return fmt.Sprintf("%s.%s.local", f.name, env)
+	}
+
+	return fmt.Sprintf("%s.%s", f.name, env)
+}
+
+// tierEnv returns the environment naming the tiers reached next, APP_ENV as
+// applied to dst so far falling back to the environment of the app
+func (l *Loader) tierEnv(dst target) string {
+	env, ok := dst.lookup(EnvKey)
+	if !ok || env == "" {
+		env = appEnv()
+	}
+	if l.opts.CaseInsensitiveEnv {
+		env = strings.ToLower(env)
+	}
+
+	return env
+}
+
+// readEnvFile parses the env file name of the precedence scheme, src is the
+// content of the file as stored on disk
+func (l *Loader) readEnvFile(file envFile, name string) (envMap map[string]string, src []byte, err error) {
+	name = l.resolvePath(name)
+	if file.required {
+		envMap, src, err = l.parseFile(name, file.secret)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("required env file %s does not exist", name)
+		}
+
+		return envMap, src, err
+	}
+
+	return l.readFile(name, file.secret)
+}
+
+// resolvePath applies Options.ExpandPaths to the name of a file about to be
+// read
+func (l *Loader) resolvePath(name string) string {
+	if !l.opts.ExpandPaths {
+		return name
+	}
+
+	return expandTilde(expandPath(name))
+}
+
+// CandidateFiles returns the env files LoadEnv tries for base path p, in
+// order of precedence. The environment tiers are named after the current
+// environment, an APP_ENV assigned by the files themselves is not read
+func (l *Loader) CandidateFiles(p string) []string {
+	return l.tierNames(l.envFiles(p))
+}
+
+// tierNames returns the names of files for the current environment, skipping
+// the tiers excluded by Options.LoadLocal
+func (l *Loader) tierNames(files []envFile) []string {
+	env := l.tierEnv(mapTarget(nil))
+
+	var names []string
+	for _, file := range files {
+		if file.local && l.opts.LoadLocal != nil && !l.opts.LoadLocal(env) {
+			continue
+		}
+		names = append(names, file.tierName(env))
+	}
+
+	return names
+}
+
+// envFiles returns the precedence scheme for base path p
+func (l *Loader) envFiles(p string) []envFile {
+	return append(append(l.defaultsFiles(), l.baseFiles(p)...), l.secretFiles()...)
+}
+
+// defaultsFiles returns Options.DefaultsFile as the lowest precedence tier,
+// if set
+func (l *Loader) defaultsFiles() []envFile {
+	if l.opts.DefaultsFile == "" {
+		return nil
+	}
+
+	return []envFile{{name: l.opts.DefaultsFile}}
+}
+
+// secretFiles returns Options.SecretFile as the highest precedence tier, if
+// set
+func (l *Loader) secretFiles() []envFile {
+	if l.opts.SecretFile == "" {
+		return nil
+	}
+
+	return []envFile{{name: l.opts.SecretFile, secret: true}}
+}
+
+// baseFiles returns the four tiers of base path p. The environment tiers are
+// named after APP_ENV as loaded by the tiers before them, so .env may choose
+// the environment
+func (l *Loader) baseFiles(p string) []envFile {
+	// base path may refer to the environment, e.g. config/${APP_ENV}/.env
+	p = expandPath(p)
+
+	return []envFile{
+		{name: p, required: l.opts.RequireBaseFile},
+		{name: fmt.Sprintf("%s.local", p), local: true},
+		{name: p, env: true},
+		{name: p, env: true, local: true},
+	}
+}
+
+// expandPath replaces ${VAR} references in p with values from the
+// environment. On Windows a backslash separates path elements and does not
+// escape a following $
+func expandPath(p string) string {
+	if filepath.Separator != '\\' {
+		return expandVariables(p, lookupEnv)
+	}
+
+	elems := strings.Split(p, `\`)
+	for i, elem := range elems {
+		elems[i] = expandVariables(elem, lookupEnv)
+	}
+
+	return strings.Join(elems, `\`)
+}
+
+// expandTilde replaces a leading ~ of p with the home directory of the user
+func expandTilde(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		return p
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+
+	return filepath.Join(home, p[1:])
+}
+
+// checkSecretMode fails when filename, described by info, is accessible by
+// group or others. Windows has no such permission bits
+func checkSecretMode(filename string, info fs.FileInfo) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		return fmt.Errorf("secret env file %s has mode %#o, expected no group or other access", filename, mode)
+	}
+
+	return nil
+}
@@ -0,0 +1,1097 @@
+package dotenv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadEnvDir(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "DIR_A", "DIR_B", "DIR_C")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadEnvDir("testdata/envdir"); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"DIR_A": "base", "DIR_B": "local", "DIR_C": "test-local"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	if after, err := os.Getwd(); err != nil || after != wd {
+		t.Errorf("working directory changed from %s to %s (%v)", wd, after, err)
+	}
+}
+
+func TestLoadEnvDirAbsolute(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "DIR_A", "DIR_B", "DIR_C", "DIR_DEFAULT")
+
+	// absolute names are not moved under dir
+	defaults := filepath.Join(t.TempDir(), "defaults.env")
+	writeFiles(t, filepath.Dir(defaults), map[string]string{"defaults.env": "DIR_DEFAULT=1\nDIR_A=default\n"})
+	base, err := filepath.Abs("testdata/envdir/.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.DefaultsFile = defaults
+	if err := NewLoader(opts).LoadEnvDir(t.TempDir(), base); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"DIR_DEFAULT": "1", "DIR_A": "base", "DIR_C": "test-local"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestLoadEnvEnvironmentInPath(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "staging")
+	unsetenv(t, "PATH_ENV_VALUE")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"staging/.env": "PATH_ENV_VALUE=staging\n"})
+
+	if err := LoadEnv(dir + "/${APP_ENV}/.env"); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("PATH_ENV_VALUE"); got != "staging" {
+		t.Errorf("PATH_ENV_VALUE = %q, want staging", got)
+	}
+}
+
+func TestEnvFromFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":            "APP_ENV=prod\nFILE_TIER=base\n",
+		".env.dev":        "FILE_TIER=dev\n",
+		".env.prod":       "FILE_TIER=prod\n",
+		".env.prod.local": "FILE_PROD_LOCAL=1\n",
+	})
+	base := filepath.Join(dir, ".env")
+
+	for _, transactional := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Transactional=%t", transactional), func(t *testing.T) {
+			keepWd(t)
+			unsetenv(t, EnvKey, "FILE_TIER", "FILE_PROD_LOCAL")
+
+			opts := DefaultOptions()
+			opts.Transactional = transactional
+			if err := NewLoader(opts).LoadEnv(base); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range map[string]string{EnvKey: "prod", "FILE_TIER": "prod", "FILE_PROD_LOCAL": "1"} {
+				if got := os.Getenv(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("read", func(t *testing.T) {
+		unsetenv(t, EnvKey)
+
+		envMap, err := NewLoader(DefaultOptions()).readFiles(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMap(t, envMap, map[string]string{EnvKey: "prod", "FILE_TIER": "prod", "FILE_PROD_LOCAL": "1"})
+		if got, ok := os.LookupEnv(EnvKey); ok {
+			t.Errorf("%s = %q, want unset", EnvKey, got)
+		}
+	})
+
+	t.Run("sync map", func(t *testing.T) {
+		keepWd(t)
+		unsetenv(t, EnvKey)
+
+		var m sync.Map
+		if err := LoadIntoSyncMap(&m, base); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := m.Load("FILE_TIER"); got != "prod" {
+			t.Errorf("FILE_TIER = %v, want prod", got)
+		}
+	})
+
+	t.Run("environment wins", func(t *testing.T) {
+		keepWd(t)
+		unsetenv(t, "FILE_TIER", "FILE_PROD_LOCAL")
+		t.Setenv(EnvKey, "dev")
+
+		if err := LoadEnv(base); err != nil {
+			t.Fatal(err)
+		}
+		for k, want := range map[string]string{EnvKey: "dev", "FILE_TIER": "dev"} {
+			if got := os.Getenv(k); got != want {
+				t.Errorf("%s = %q, want %q", k, got, want)
+			}
+		}
+	})
+
+	t.Run("default set after the load", func(t *testing.T) {
+		keepWd(t)
+		unsetenv(t, EnvKey, "FILE_TIER")
+
+		other := t.TempDir()
+		writeFiles(t, other, map[string]string{".env": "FILE_TIER=base\n", ".env.dev": "FILE_TIER=dev\n"})
+		if err := LoadEnv(filepath.Join(other, ".env")); err != nil {
+			t.Fatal(err)
+		}
+		for k, want := range map[string]string{EnvKey: DefaultEnv, "FILE_TIER": "dev"} {
+			if got := os.Getenv(k); got != want {
+				t.Errorf("%s = %q, want %q", k, got, want)
+			}
+		}
+	})
+}
+
+func TestBuildEnv(t *testing.T) {
+	keepWd(t)
+	unsetenv(t, EnvKey, "BUILD_TIER")
+
+	buildEnv := BuildEnv
+	BuildEnv = "staging"
+	t.Cleanup(func() { BuildEnv = buildEnv })
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env.staging": "BUILD_TIER=staging\n", ".env.dev": "BUILD_TIER=dev\n"})
+	base := filepath.Join(dir, ".env")
+
+	if got := CandidateFiles(base); !slices.Contains(got, base+".staging") {
+		t.Errorf("got candidates %q, want the baked-in environment", got)
+	}
+	if err := LoadEnv(base); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{EnvKey: "staging", "BUILD_TIER": "staging"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	// a real APP_ENV wins over the baked-in one
+	t.Setenv(EnvKey, "dev")
+	if got := CandidateFiles(base); !slices.Contains(got, base+".dev") {
+		t.Errorf("got candidates %q, want APP_ENV over BuildEnv", got)
+	}
+}
+
+func TestEmptyMeansUnset(t *testing.T) {
+	for _, unset := range []bool{false, true} {
+		t.Run(fmt.Sprintf("EmptyMeansUnset=%t", unset), func(t *testing.T) {
+			keepWd(t)
+			t.Setenv(EnvKey, "test")
+			unsetenv(t, "EMPTY_OVERRIDDEN", "EMPTY_NEW")
+
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				".env":       "EMPTY_OVERRIDDEN=1\nEMPTY_NEW=\n",
+				".env.local": "EMPTY_OVERRIDDEN=\n",
+			})
+
+			opts := DefaultOptions()
+			opts.EmptyMeansUnset = unset
+			if err := NewLoader(opts).LoadEnv(filepath.Join(dir, ".env")); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, k := range []string{"EMPTY_OVERRIDDEN", "EMPTY_NEW"} {
+				if v, ok := os.LookupEnv(k); ok == unset || v != "" {
+					t.Errorf("%s = %q (set %t), want set %t", k, v, ok, !unset)
+				}
+			}
+
+			envMap, err := NewLoader(opts).Parse(strings.NewReader("A=\nB=1\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"A": "", "B": "1"}
+			if unset {
+				want = map[string]string{"B": "1"}
+			}
+			assertMap(t, envMap, want)
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "PROT_NEW", "PROT_KEPT", "OPEN")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "PROT_KEPT=1\n"})
+	base := filepath.Join(dir, ".env")
+
+	opts := DefaultOptions()
+	opts.EmptyMeansUnset = true
+	l := NewLoader(opts)
+	if err := l.LoadEnv(base); err != nil {
+		t.Fatal(err)
+	}
+
+	// later loads of the same loader may normally change PROT_KEPT
+	l.opts.Protect = []string{"PROT_NEW", "PROT_KEPT"}
+	writeFiles(t, dir, map[string]string{".env": "PROT_NEW=1\nPROT_KEPT=\nOPEN=1\n"})
+	if err := l.LoadEnv(base); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := os.LookupEnv("PROT_NEW"); ok {
+		t.Errorf("PROT_NEW = %q, want never set", v)
+	}
+	for k, want := range map[string]string{"PROT_KEPT": "1", "OPEN": "1"} {
+		if got, ok := os.LookupEnv(k); !ok || got != want {
+			t.Errorf("%s = %q (set %t), want %q", k, got, ok, want)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Transform = func(key, value string) (string, bool) {
+		if key == "DROPPED" {
+			return "", false
+		}
+		return strings.TrimPrefix(value, "enc:"), true
+	}
+
+	got := loadMap(t, opts, map[string]string{".env": "SECRET=enc:abc\nPLAIN=x\nDROPPED=1\n"})
+	assertMap(t, got, map[string]string{"SECRET": "abc", "PLAIN": "x"})
+}
+
+func TestRename(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Rename = map[string]string{"OLD_NAME": "NEW_NAME", "OLD_PORT": "PORT"}
+
+	got := loadMap(t, opts, map[string]string{".env": "OLD_NAME=x\nOTHER=y\nOLD_PORT=1\nPORT=2\n"})
+	// an explicit assignment wins over a key renamed to it
+	assertMap(t, got, map[string]string{"NEW_NAME": "x", "OTHER": "y", "PORT": "2"})
+}
+
+func TestDeprecated(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Deprecated = map[string]string{"OLD_HOST": "HOST"}
+
+	l := NewLoader(opts)
+	if _, err := l.Parse(strings.NewReader("A=1\nOLD_HOST=a\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := []Warning{{Line: 2, Msg: "OLD_HOST is deprecated, use HOST instead"}}
+	if got := l.Warnings(); !slices.Equal(got, want) {
+		t.Errorf("got warnings %v, want %v", got, want)
+	}
+
+	assertMap(t, loadMap(t, opts, map[string]string{".env": "OLD_HOST=a\n"}), map[string]string{"OLD_HOST": "a"})
+
+	// the old key stays loaded, an explicit replacement wins over it
+	opts.RemapDeprecated = true
+	assertMap(t, loadMap(t, opts, map[string]string{".env": "OLD_HOST=a\n"}), map[string]string{"OLD_HOST": "a", "HOST": "a"})
+	assertMap(t, loadMap(t, opts, map[string]string{".env": "OLD_HOST=a\nHOST=b\n"}), map[string]string{"OLD_HOST": "a", "HOST": "b"})
+}
+
+func TestBuiltinVars(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "__FILE__", "__LINE__")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":       "BASE_AT=${__FILE__}:${__LINE__}\n",
+		".env.local": "# local overrides\n\nLOCAL_AT=\"${__FILE__}:${__LINE__}\"\n",
+	})
+	base := filepath.Join(dir, ".env")
+
+	for _, tt := range []struct {
+		enabled bool
+		want    map[string]string
+	}{
+		{false, map[string]string{"BASE_AT": ":", "LOCAL_AT": ":"}},
+		{true, map[string]string{"BASE_AT": base + ":1", "LOCAL_AT": base + ".local:3"}},
+	} {
+		opts := DefaultOptions()
+		opts.BuiltinVars = tt.enabled
+
+		out := make(mapTarget)
+		l := NewLoader(opts)
+		if err := l.applyFiles(l.envFiles(base), nil, out, nil); err != nil {
+			t.Fatal(err)
+		}
+		assertMap(t, out, tt.want)
+	}
+}
+
+func TestLoadChain(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "CHAIN_USER", "CHAIN_PROJECT", "CHAIN_SHARED")
+
+	user, project := t.TempDir(), t.TempDir()
+	writeFiles(t, user, map[string]string{".env": "CHAIN_USER=user\nCHAIN_SHARED=user\n"})
+	writeFiles(t, project, map[string]string{
+		".env":      "CHAIN_PROJECT=project\n",
+		".env.test": "CHAIN_SHARED=project\n",
+	})
+
+	if err := LoadChain(filepath.Join(user, ".env"), filepath.Join(project, ".env")); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"CHAIN_USER": "user", "CHAIN_PROJECT": "project", "CHAIN_SHARED": "project"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	got, err := ParseInline(`A=1;B='two';C="x;y"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, got, map[string]string{"A": "1", "B": "two", "C": "x;y"})
+
+	got, err = ParseInline("A=1; B=${A}2\nC=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, got, map[string]string{"A": "1", "B": "12", "C": "3"})
+}
+
+func TestCaseInsensitiveEnv(t *testing.T) {
+	t.Setenv(EnvKey, "Production")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env.production": "CASE_ENV=production\n"})
+
+	for _, insensitive := range []bool{false, true} {
+		if _, err := os.Stat(filepath.Join(dir, ".env.Production")); err == nil && !insensitive {
+			// the file system ignores case itself
+			continue
+		}
+
+		opts := DefaultOptions()
+		opts.CaseInsensitiveEnv = insensitive
+		l := NewLoader(opts)
+
+		out := make(mapTarget)
+		if err := l.applyFiles(l.envFiles(filepath.Join(dir, ".env")), nil, out, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{}
+		if insensitive {
+			want["CASE_ENV"] = "production"
+		}
+		assertMap(t, out, want)
+	}
+}
+
+func TestLoadIfSet(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "IFSET_SENTINEL", "IFSET_A")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "IFSET_A=1\n"})
+	path := filepath.Join(dir, ".env")
+
+	if err := LoadIfSet("IFSET_SENTINEL", path); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := os.LookupEnv("IFSET_A"); ok {
+		t.Errorf("sentinel unset: IFSET_A = %q, want nothing loaded", v)
+	}
+
+	t.Setenv("IFSET_SENTINEL", "1")
+	if err := LoadIfSet("IFSET_SENTINEL", path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("IFSET_A"); got != "1" {
+		t.Errorf("sentinel set: IFSET_A = %q, want 1", got)
+	}
+}
+
+func TestListMergeKeys(t *testing.T) {
+	files := map[string]string{
+		".env":            "PLUGINS=a,b\nTAGS=x\n",
+		".env.local":      "PLUGINS=b, c\nTAGS=y\n",
+		".env.test.local": "PLUGINS=d\n",
+	}
+
+	opts := DefaultOptions()
+	assertMap(t, loadMap(t, opts, files), map[string]string{"PLUGINS": "d", "TAGS": "y"})
+
+	// listed keys take the union in tier order, the others are replaced
+	opts.ListMergeKeys = []string{"PLUGINS"}
+	assertMap(t, loadMap(t, opts, files), map[string]string{"PLUGINS": "a,b,c,d", "TAGS": "y"})
+}
+
+func TestLoadEnvKeys(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "KEYS_LISTED", "KEYS_UNLISTED")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "KEYS_LISTED=1\nKEYS_UNLISTED=2\n"})
+
+	if err := LoadEnvKeys([]string{"KEYS_LISTED"}, filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("KEYS_LISTED"); got != "1" {
+		t.Errorf("KEYS_LISTED = %q, want 1", got)
+	}
+	if got, ok := os.LookupEnv("KEYS_UNLISTED"); ok {
+		t.Errorf("KEYS_UNLISTED = %q, want unset", got)
+	}
+}
+
+func TestLoadRequired(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	t.Setenv("REQ_OS", "os")
+	unsetenv(t, "REQ_FILE", "REQ_EMPTY", "REQ_MISSING", "REQ_ALSO_MISSING")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "REQ_FILE=1\nREQ_EMPTY=\n"})
+	path := filepath.Join(dir, ".env")
+
+	if err := LoadRequired([]string{"REQ_FILE", "REQ_OS"}, path); err != nil {
+		t.Errorf("all present: got %v", err)
+	}
+
+	// every missing or empty key is reported
+	err := LoadRequired([]string{"REQ_FILE", "REQ_EMPTY", "REQ_MISSING", "REQ_ALSO_MISSING"}, path)
+	if err == nil {
+		t.Fatal("expected an error for the missing keys")
+	}
+	for _, k := range []string{"REQ_EMPTY", "REQ_MISSING", "REQ_ALSO_MISSING"} {
+		if !strings.Contains(err.Error(), "required variable "+k+" is not set") {
+			t.Errorf("got %q, want %s reported", err, k)
+		}
+	}
+	if strings.Contains(err.Error(), "REQ_FILE") {
+		t.Errorf("got %q, want REQ_FILE not reported", err)
+	}
+}
+
+func TestRequireBaseFile(t *testing.T) {
+	opts := DefaultOptions()
+	opts.RequireBaseFile = true
+
+	// the derived tiers stay optional
+	got := loadMap(t, opts, map[string]string{".env": "BASE=1\n"})
+	assertMap(t, got, map[string]string{"BASE": "1"})
+
+	l := NewLoader(opts)
+	err := l.applyFiles(l.envFiles(filepath.Join(t.TempDir(), ".env")), nil, make(mapTarget), nil)
+	if err == nil || !strings.Contains(err.Error(), "required env file") {
+		t.Errorf("got %v, want a missing required file error", err)
+	}
+}
+
+func TestLoadIntoSyncMap(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":      "SYNC_A=1\nSYNC_B=base\n",
+		".env.test": "SYNC_B=test\n",
+	})
+
+	var m sync.Map
+	m.Store("SYNC_B", "kept")
+
+	// readers run during the load and after it, -race checks the access
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					m.Load("SYNC_A")
+					m.Load("SYNC_B")
+				}
+			}
+		}()
+	}
+
+	err := LoadIntoSyncMap(&m, filepath.Join(dir, ".env"))
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"SYNC_A": "1", "SYNC_B": "kept"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, _ := m.Load(k); got != want {
+				t.Errorf("%s = %v, want %q", k, got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadEnvDirectory(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+
+	// the base path names a directory, the derived tiers do not exist
+	if err := LoadEnv(t.TempDir()); err == nil || !strings.Contains(err.Error(), "path is a directory") {
+		t.Errorf("got %v, want a directory error", err)
+	}
+}
+
+func TestLoadLocal(t *testing.T) {
+	files := map[string]string{
+		".env":            "TIER=base\n",
+		".env.local":      "TIER=local\nLOCAL=1\n",
+		".env.test":       "TIER=test\n",
+		".env.test.local": "TIER=test.local\nTEST_LOCAL=1\n",
+	}
+
+	var envs []string
+	opts := DefaultOptions()
+	opts.LoadLocal = func(env string) bool {
+		envs = append(envs, env)
+		return env != "test"
+	}
+	assertMap(t, loadMap(t, opts, files), map[string]string{"TIER": "test"})
+	if !slices.Equal(envs, []string{"test", "test"}) {
+		t.Errorf("LoadLocal called with %q, want the resolved environment for each .local tier", envs)
+	}
+
+	opts.LoadLocal = func(env string) bool { return env == "test" }
+	assertMap(t, loadMap(t, opts, files), map[string]string{"TIER": "test.local", "LOCAL": "1", "TEST_LOCAL": "1"})
+}
+
+func TestLoadManifest(t *testing.T) {
+	keepWd(t)
+	unsetenv(t, "MANIFEST_A", "MANIFEST_B", "MANIFEST_C")
+
+	dir := t.TempDir()
+	other := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.env":      "MANIFEST_A=base\nMANIFEST_B=base\n",
+		"conf/over.env": "MANIFEST_B=over\n",
+	})
+	writeFiles(t, other, map[string]string{"abs.env": "MANIFEST_C=abs\n"})
+	writeFiles(t, dir, map[string]string{"manifest": "# loaded in order\nbase.env\n\n  conf/over.env  \n# missing.env\n" +
+		filepath.Join(other, "abs.env") + "\n"})
+
+	// strict files fail on the commented out entry unless it is skipped
+	opts := DefaultOptions()
+	opts.StrictFiles = true
+	if err := NewLoader(opts).LoadManifest(filepath.Join(dir, "manifest")); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"MANIFEST_A": "base", "MANIFEST_B": "over", "MANIFEST_C": "abs"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestLoadEnvRaw(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "RAW_A", "RAW_B")
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte("RAW_B=compressed\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "RAW_A=plain\n", ".env.test.local": compressed.String()})
+
+	raw, err := LoadEnvRaw(filepath.Join(dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// missing tiers are left out, the others come back as stored on disk
+	if len(raw) != 2 {
+		t.Errorf("got raw content of %d files, want 2", len(raw))
+	}
+	for _, name := range []string{".env", ".env.test.local"} {
+		want, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := raw[filepath.Join(dir, name)]; !bytes.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	for k, want := range map[string]string{"RAW_A": "plain", "RAW_B": "compressed"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestReloadIfChanged(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "RELOAD_A", "RELOAD_B")
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+
+	// every change moves the modification time on, whatever the clock
+	// granularity of the file system
+	mtime := time.Now().Add(-time.Hour)
+	touch := func(name, content string) {
+		t.Helper()
+		writeFiles(t, dir, map[string]string{name: content})
+		mtime = mtime.Add(time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reload := func(l *Loader, wantChanged bool, want map[string]string) {
+		t.Helper()
+		changed, err := l.ReloadIfChanged(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed = %t, want %t", changed, wantChanged)
+		}
+		for k, v := range want {
+			if got := os.Getenv(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	}
+
+	l := NewLoader(DefaultOptions())
+	touch(".env", "RELOAD_A=1\nRELOAD_B=1\n")
+	reload(l, true, map[string]string{"RELOAD_A": "1", "RELOAD_B": "1"})
+
+	// an unchanged call does not apply the files again
+	t.Setenv("RELOAD_A", "manual")
+	reload(l, false, map[string]string{"RELOAD_A": "manual"})
+
+	// every tier is reloaded, overriding the values set by the loader
+	touch(".env", "RELOAD_A=2\nRELOAD_B=2\n")
+	reload(l, true, map[string]string{"RELOAD_A": "2", "RELOAD_B": "2"})
+
+	touch(".env.test.local", "RELOAD_B=local\n")
+	reload(l, true, map[string]string{"RELOAD_A": "2", "RELOAD_B": "local"})
+
+	// a failed load keeps the former modification times, so the next call
+	// tries again
+	touch(".env", "RELOAD_A=\"unterminated\n")
+	for range 2 {
+		if changed, err := l.ReloadIfChanged(base); err == nil || changed {
+			t.Fatalf("got %t, %v, want a parse error", changed, err)
+		}
+	}
+
+	touch(".env", "RELOAD_A=3\n")
+	reload(l, true, map[string]string{"RELOAD_A": "3", "RELOAD_B": "local"})
+	reload(l, false, nil)
+}
+
+func TestTransactional(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":            "TX_A=1\n",
+		".env.test.local": "TX_B=${TX_UNDEFINED}\n",
+	})
+	base := filepath.Join(dir, ".env")
+
+	for name, load := range map[string]func(l *Loader) error{
+		"LoadEnv":    func(l *Loader) error { return l.LoadEnv(base) },
+		"LoadEnvRaw": func(l *Loader) error { _, err := l.LoadEnvRaw(base); return err },
+	} {
+		t.Run(name, func(t *testing.T) {
+			keepWd(t)
+			t.Setenv(EnvKey, "test")
+			unsetenv(t, "TX_A", "TX_B", "TX_UNDEFINED")
+
+			// the strict error in the last tier leaves the former unapplied
+			opts := DefaultOptions()
+			opts.OnUndefined = UndefinedError
+			opts.Transactional = true
+			if err := load(NewLoader(opts)); err == nil || !strings.Contains(err.Error(), "undefined variable TX_UNDEFINED") {
+				t.Fatalf("got %v, want an undefined variable error", err)
+			}
+			if v, ok := os.LookupEnv("TX_A"); ok {
+				t.Errorf("TX_A = %q, want the environment unchanged", v)
+			}
+
+			opts.Transactional = false
+			if err := load(NewLoader(opts)); err == nil {
+				t.Fatal("expected an undefined variable error")
+			}
+			if got := os.Getenv("TX_A"); got != "1" {
+				t.Errorf("TX_A = %q, want the former tiers applied without Transactional", got)
+			}
+		})
+	}
+}
+
+func TestLoadEnvGzip(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "GZ_A", "GZ_B")
+
+	path, err := filepath.Abs("testdata/gzip/.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"GZ_A": "compressed", "GZ_B": "compressed value"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "\x1f\x8bnot gzip"})
+	if _, err := ReadFile(filepath.Join(dir, ".env")); err == nil || !strings.Contains(err.Error(), filepath.Join(dir, ".env")) {
+		t.Errorf("got %v, want a gzip error naming the file", err)
+	}
+}
+
+// slowFile is an env file whose reads block until release is closed
+type slowFile struct {
+	file    *os.File
+	release chan struct{}
+}
+
+func (f slowFile) Stat() (fs.FileInfo, error) { return f.file.Stat() }
+func (f slowFile) Close() error               { return f.file.Close() }
+
+func (f slowFile) Read(b []byte) (int, error) {
+	<-f.release
+	return f.file.Read(b)
+}
+
+func TestLoadEnvTimeout(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "TIMEOUT_A")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "TIMEOUT_A=1\n"})
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	l := NewLoader(DefaultOptions())
+	l.open = func(name string) (fs.File, error) {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return slowFile{file: file, release: release}, nil
+	}
+
+	err := l.LoadEnvTimeout(10*time.Millisecond, filepath.Join(dir, ".env"))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want a deadline error", err)
+	}
+	if v, ok := os.LookupEnv("TIMEOUT_A"); ok {
+		t.Errorf("TIMEOUT_A = %q, want unset after the timeout", v)
+	}
+
+	if err := LoadEnvTimeout(time.Minute, filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("TIMEOUT_A"); got != "1" {
+		t.Errorf("TIMEOUT_A = %q, want 1", got)
+	}
+}
+
+func TestOnFileError(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env":      "BASE=1\n",
+		".env.test": "TIER=test\n",
+	})
+	// a directory in place of the .local tier fails to read
+	if err := os.Mkdir(filepath.Join(dir, ".env.local"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var failed []string
+	opts := DefaultOptions()
+	opts.OnFileError = func(path string, err error) error {
+		failed = append(failed, filepath.Base(path))
+		return nil
+	}
+	l := NewLoader(opts)
+	out := make(mapTarget)
+	if err := l.applyFiles(l.envFiles(filepath.Join(dir, ".env")), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, out, map[string]string{"BASE": "1", "TIER": "test"})
+	if len(failed) != 1 || failed[0] != ".env.local" {
+		t.Errorf("OnFileError called for %v, want .env.local only", failed)
+	}
+
+	abort := errors.New("abort")
+	opts.OnFileError = func(string, error) error { return abort }
+	l = NewLoader(opts)
+	if err := l.applyFiles(l.envFiles(filepath.Join(dir, ".env")), nil, make(mapTarget), nil); !errors.Is(err, abort) {
+		t.Errorf("got %v, want the error returned by OnFileError", err)
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	home, cfg := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("PATHS_CFG", cfg)
+	unsetenv(t, "PATHS_BASE", "PATHS_DEFAULT", "PATHS_SECRET", "PATHS_FILE", "PATHS_MANIFEST", "PATHS_GLOB")
+
+	writeFiles(t, home, map[string]string{
+		"app/.env":      "PATHS_BASE=base\n",
+		"defaults.env":  "PATHS_DEFAULT=default\n",
+		"files/a.env":   "PATHS_FILE=file\n",
+		"list/manifest": "# relative to the manifest\nm.env\n",
+		"list/m.env":    "PATHS_MANIFEST=manifest\n",
+	})
+	writeFiles(t, cfg, map[string]string{"secret.env": "PATHS_SECRET=secret\n", "glob/x.env": "PATHS_GLOB=glob\n"})
+	if err := os.Chmod(filepath.Join(cfg, "secret.env"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ExpandPaths = true
+	opts.DefaultsFile = "~/defaults.env"
+	opts.SecretFile = "${PATHS_CFG}/secret.env"
+	l := NewLoader(opts)
+
+	out := make(mapTarget)
+	if err := l.applyFiles(l.envFiles("~/app/.env"), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, out, map[string]string{"PATHS_BASE": "base", "PATHS_DEFAULT": "default", "PATHS_SECRET": "secret"})
+
+	l = NewLoader(opts)
+	if err := l.LoadFiles("~/files/a.env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.LoadManifest("~/list/manifest"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.LoadGlob("$PATHS_CFG/glob/*.env"); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"PATHS_FILE": "file", "PATHS_MANIFEST": "manifest", "PATHS_GLOB": "glob"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	// without the option ~ is a plain directory name
+	out = make(mapTarget)
+	l = NewLoader(DefaultOptions())
+	if err := l.applyFiles(l.envFiles("~/app/.env"), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, out, map[string]string{})
+}
+
+func TestFreeze(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+	unsetenv(t, "FREEZE_KEPT", "FREEZE_RELOADED")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "FREEZE_KEPT=1\nFREEZE_RELOADED=1\n"})
+
+	l := NewLoader(DefaultOptions())
+	if err := l.LoadEnv(filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	l.Freeze("FREEZE_KEPT")
+
+	// values set by the loader itself are overridden by its later loads
+	writeFiles(t, dir, map[string]string{".env": "FREEZE_KEPT=2\nFREEZE_RELOADED=2\n"})
+	if err := l.LoadEnv(filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, want := range map[string]string{"FREEZE_KEPT": "1", "FREEZE_RELOADED": "2"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestLoadGlob(t *testing.T) {
+	keepWd(t)
+	unsetenv(t, "GLOB_A", "GLOB_B", "GLOB_SHARED", "GLOB_SKIPPED")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"conf.d/10-base.env":  "GLOB_A=1\nGLOB_SHARED=base\n",
+		"conf.d/20-later.env": "GLOB_B=2\nGLOB_SHARED=later\n",
+		"conf.d/README":       "GLOB_SKIPPED=1\n",
+	})
+
+	if err := LoadGlob(filepath.Join(dir, "conf.d", "*.env")); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"GLOB_A": "1", "GLOB_B": "2", "GLOB_SHARED": "later"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if got, ok := os.LookupEnv("GLOB_SKIPPED"); ok {
+		t.Errorf("GLOB_SKIPPED = %q, want unset", got)
+	}
+
+	if err := LoadGlob(filepath.Join(dir, "none", "*.env")); err != nil {
+		t.Errorf("empty match: got %v, want nil", err)
+	}
+}
+
+func TestStrictFiles(t *testing.T) {
+	keepWd(t)
+	unsetenv(t, "FILES_A", "FILES_B")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.env": "FILES_A=1\n", "b.env": "FILES_B=2\n"})
+	missing := filepath.Join(dir, "missing.env")
+	paths := []string{filepath.Join(dir, "a.env"), missing, filepath.Join(dir, "b.env")}
+
+	opts := DefaultOptions()
+	opts.StrictFiles = true
+	err := NewLoader(opts).LoadFiles(paths...)
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("strict: got %v, want an error naming %s", err, missing)
+	}
+	if v, ok := os.LookupEnv("FILES_B"); ok {
+		t.Errorf("strict: FILES_B = %q, want the files after the missing one skipped", v)
+	}
+
+	if err := LoadFiles(paths...); err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	for k, want := range map[string]string{"FILES_A": "1", "FILES_B": "2"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("lenient: %s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestDefaultsFile(t *testing.T) {
+	t.Setenv(EnvKey, "test")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".env.defaults": "DEF_ONLY=default\nDEF_BASE=default\nDEF_TIER=default\n",
+		".env":          "DEF_BASE=base\nDEF_TIER=base\n",
+		".env.test":     "DEF_TIER=test\n",
+	})
+
+	opts := DefaultOptions()
+	opts.DefaultsFile = filepath.Join(dir, ".env.defaults")
+	l := NewLoader(opts)
+
+	files := l.CandidateFiles(filepath.Join(dir, ".env"))
+	if files[0] != opts.DefaultsFile {
+		t.Errorf("candidates %v, want the defaults file first", files)
+	}
+
+	out := make(mapTarget)
+	if err := l.applyFiles(l.envFiles(filepath.Join(dir, ".env")), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, out, map[string]string{"DEF_ONLY": "default", "DEF_BASE": "base", "DEF_TIER": "test"})
+}
+
+func TestSecretFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits on Windows")
+	}
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secrets.env")
+	opts := DefaultOptions()
+	opts.SecretFile = secret
+
+	// a missing secret file passes like any other optional tier
+	assertMap(t, loadMap(t, opts, map[string]string{".env": "A=1\n"}), map[string]string{"A": "1"})
+
+	writeFiles(t, dir, map[string]string{"secrets.env": "TOKEN=x\n"})
+	if err := os.Chmod(secret, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := make(mapTarget)
+	l := NewLoader(opts)
+	err := l.applyFiles(l.secretFiles(), nil, out, nil)
+	if err == nil || !strings.Contains(err.Error(), "has mode 0644") {
+		t.Fatalf("got %v, want a mode error for 0644", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("got %v, want nothing applied", out)
+	}
+
+	if err := os.Chmod(secret, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.applyFiles(l.secretFiles(), nil, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, out, map[string]string{"TOKEN": "x"})
+}
+
+func TestCandidateFilesWindowsPath(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Windows paths only")
+	}
+	t.Setenv(EnvKey, "test")
+
+	// a backslash separates path elements and does not escape the $
+	got := CandidateFiles(`C:\app\${APP_ENV}\.env`)
+	want := []string{`C:\app\test\.env`, `C:\app\test\.env.local`, `C:\app\test\.env.test`, `C:\app\test\.env.test.local`}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
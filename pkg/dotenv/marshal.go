@@ -0,0 +1,228 @@
+package dotenv
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultFileMode os.FileMode = 0644
+	secretFileMode  os.FileMode = 0600
+)
+
+var valueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	`$`, `\$`,
+)
+
+// Marshal renders env map as env file content, one sorted KEY=value per line
+func Marshal(envMap map[string]string) ([]byte, error) {
+	return NewLoader(DefaultOptions()).Marshal(envMap)
+}
+
+// Marshal renders env map as env file content, one sorted KEY=value per line
+// ended by Options.LineEnding
+func (l *Loader) Marshal(envMap map[string]string) ([]byte, error) {
+	lineEnding := l.opts.LineEnding
+	switch lineEnding {
+	case "":
+		lineEnding = "\n"
+	case "\n", "\r\n":
+	default:
+		return nil, fmt.Errorf("unsupported line ending %q", lineEnding)
+	}
+
+	var sb strings.Builder
+	for _, k := range sortedKeys(envMap) {
+		// keys that are not plain variable names are written quoted
+		if err := validateKey(k); err != nil && !isQuotableKey(k) {
+			return nil, err
+		}
+		sb.WriteString(quoteKey(k))
+		sb.WriteByte('=')
+		sb.WriteString(quoteValue(envMap[k]))
+		sb.WriteString(lineEnding)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// MarshalPairs renders pairs like Marshal, a key assigned more than once is
+// written once with its last value
+func MarshalPairs(pairs []Pair) ([]byte, error) {
+	return NewLoader(DefaultOptions()).MarshalPairs(pairs)
+}
+
+// MarshalPairs renders pairs like Marshal, a key assigned more than once is
+// written once with its last value
+func (l *Loader) MarshalPairs(pairs []Pair) ([]byte, error) {
+	envMap := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		envMap[pair.Key] = pair.Value
+	}
+
+	return l.Marshal(envMap)
+}
+
+// MarshalShell renders env map as sorted export KEY='value' lines that a
+// POSIX shell can source or eval. Keys that are not valid shell variable
+// names are skipped
+func MarshalShell(envMap map[string]string) []byte {
+	var sb strings.Builder
+	for _, k := range sortedKeys(envMap) {
+		if !posixKeyRegex.MatchString(k) {
+			continue
+		}
+		sb.WriteString(exportPrefix + " " + k + "=")
+		sb.WriteString(shellQuote(envMap[k]))
+		sb.WriteByte('\n')
+	}
+
+	return []byte(sb.String())
+}
+
+// WriteFile writes env map to filename using the default options
+func WriteFile(filename string, envMap map[string]string) error {
+	return NewLoader(DefaultOptions()).WriteFile(filename, envMap)
+}
+
+// DumpEnv merges env files by path and writes the result to filename using
+// the default options
+func DumpEnv(filename string, path ...string) error {
+	return NewLoader(DefaultOptions()).DumpEnv(filename, path...)
+}
+
+// WriteFile writes env map to filename, files holding secret keys are
+// always written with 0600. Other existing files keep their mode
+func (l *Loader) WriteFile(filename string, envMap map[string]string) error {
+	data, err := l.Marshal(envMap)
+	if err != nil {
+		return err
+	}
+
+	secret := l.hasSecretKey(envMap)
+	mode := defaultFileMode
+	if secret {
+		mode = secretFileMode
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	// an existing file keeps its mode, narrow it before any secret is written
+	if secret {
+		if err = file.Chmod(secretFileMode); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// DumpEnv merges env files by path, in order of precedence, and writes the
+// result to filename
+func (l *Loader) DumpEnv(filename string, path ...string) error {
+	envMap, err := l.readFiles(path...)
+	if err != nil {
+		return err
+	}
+
+	return l.WriteFile(filename, envMap)
+}
+
+func sortedKeys(envMap map[string]string) []string {
+	keys := make([]string, 0, len(envMap))
+	for k := range envMap {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func (l *Loader) hasSecretKey(envMap map[string]string) bool {
+	for k := range envMap {
+		if l.isSecretKey(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *Loader) isSecretKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, pattern := range l.opts.SecretKeyPatterns {
+		if pattern != "" && strings.Contains(key, strings.ToUpper(pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty variable name")
+	}
+	for _, r := range key {
+		if !isKeyChar(r) {
+			return fmt.Errorf("unexpected character %q in variable name %q", r, key)
+		}
+	}
+
+	return nil
+}
+
+func isKeyChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '.'
+}
+
+// quoteKey quotes key when it is only valid as a quoted variable name
+func quoteKey(key string) string {
+	if validateKey(key) == nil {
+		return key
+	}
+	if strings.ContainsRune(key, prefixDoubleQuote) {
+		return "'" + key + "'"
+	}
+
+	return `"` + key + `"`
+}
+
+// isQuotableKey reports whether key can be written as a quoted variable name
+func isQuotableKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "\r\n") && !(strings.ContainsRune(key, prefixDoubleQuote) && strings.ContainsRune(key, prefixSingleQuote))
+}
+
+func quoteValue(value string) string {
+	if !strings.ContainsFunc(value, needsQuoting) {
+		return value
+	}
+
+	return `"` + valueEscaper.Replace(value) + `"`
+}
+
+func needsQuoting(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !strings.ContainsRune("_-./:,@+%=", r)
+}
+
+// shellQuote single-quotes value, a single quote inside closes the quoting,
+// is escaped and reopens it
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,212 @@
+package dotenv
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits on Windows")
+	}
+
+	opts := DefaultOptions()
+	opts.SecretKeyPatterns = []string{"PASSWORD"}
+	l := NewLoader(opts)
+
+	tests := []struct {
+		name     string
+		envMap   map[string]string
+		existing os.FileMode // 0 creates a new file
+		check    func(mode os.FileMode) bool
+		want     string
+	}{
+		{
+			name:   "secret new file",
+			envMap: map[string]string{"DB_PASSWORD": "x"},
+			check:  func(mode os.FileMode) bool { return mode == secretFileMode },
+			want:   "0600",
+		},
+		{
+			name:     "secret existing file",
+			envMap:   map[string]string{"DB_PASSWORD": "x"},
+			existing: 0o644,
+			check:    func(mode os.FileMode) bool { return mode == secretFileMode },
+			want:     "0600",
+		},
+		{
+			name:   "plain new file",
+			envMap: map[string]string{"NAME": "x"},
+			// the umask may narrow the default mode further
+			check: func(mode os.FileMode) bool { return mode&^defaultFileMode == 0 },
+			want:  "at most 0644",
+		},
+		{
+			name:     "plain existing file",
+			envMap:   map[string]string{"NAME": "x"},
+			existing: 0o640,
+			check:    func(mode os.FileMode) bool { return mode == 0o640 },
+			want:     "unchanged 0640",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), ".env")
+			if tt.existing != 0 {
+				if err := os.WriteFile(filename, []byte("OLD=1\n"), tt.existing); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(filename, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := l.WriteFile(filename, tt.envMap); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); !tt.check(mode) {
+				t.Errorf("mode %#o, want %s", mode, tt.want)
+			}
+
+			envMap, err := l.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertMap(t, envMap, tt.envMap)
+		})
+	}
+}
+
+func TestMarshalLineEnding(t *testing.T) {
+	envMap := map[string]string{"A": "1", "B": "two words"}
+	for _, tt := range []struct {
+		lineEnding, want string
+	}{
+		{"", "A=1\nB=\"two words\"\n"},
+		{"\n", "A=1\nB=\"two words\"\n"},
+		{"\r\n", "A=1\r\nB=\"two words\"\r\n"},
+	} {
+		opts := DefaultOptions()
+		opts.LineEnding = tt.lineEnding
+		got, err := NewLoader(opts).Marshal(envMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("line ending %q: got %q, want %q", tt.lineEnding, got, tt.want)
+		}
+		assertMap(t, mustParse(t, opts, string(got)), envMap)
+	}
+
+	opts := DefaultOptions()
+	opts.LineEnding = "\r"
+	if _, err := NewLoader(opts).Marshal(envMap); err == nil || !strings.Contains(err.Error(), "unsupported line ending") {
+		t.Errorf("got %v, want an unsupported line ending error", err)
+	}
+}
+
+func TestMarshalPairs(t *testing.T) {
+	got, err := MarshalPairs([]Pair{{"B", "1"}, {"A", "x"}, {"B", "2"}, {"B", "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "A=x\nB=3\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarshalShellSourced(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to source the output with")
+	}
+
+	envMap := map[string]string{
+		"QUOTE":   "it's 'quoted'",
+		"DOLLAR":  "$HOME and ${PATH} `id`",
+		"NEWLINE": "first\nsecond\n",
+		"MIXED":   `back\slash "double" ;&|`,
+		"EMPTY":   "",
+	}
+	keys := sortedKeys(envMap)
+
+	script := string(MarshalShell(envMap)) + `printf '%s\000'`
+	for _, k := range keys {
+		script += ` "$` + k + `"`
+	}
+
+	out, err := exec.Command(sh, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("sourcing %q: %v", script, err)
+	}
+
+	values := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if len(values) != len(keys) {
+		t.Fatalf("got %q, want %d values", out, len(keys))
+	}
+	for i, k := range keys {
+		if values[i] != envMap[k] {
+			t.Errorf("%s: got %q, want %q", k, values[i], envMap[k])
+		}
+	}
+}
+
+func TestMarshalShellSkipsInvalidNames(t *testing.T) {
+	got := string(MarshalShell(map[string]string{"GOOD": "1", "my.key": "2", "1BAD": "3"}))
+	if want := "export GOOD='1'\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarshalQuotedKeys(t *testing.T) {
+	envMap := map[string]string{
+		"PLAIN":        "1",
+		"My.Weird-Key": "2",
+		"with space":   "3",
+		`say "hi"`:     "4",
+		"it's":         "5",
+		"a=b:c":        "6",
+	}
+
+	data, err := Marshal(envMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\"My.Weird-Key\"=2\n") || !strings.Contains(string(data), "'say \"hi\"'=4\n") {
+		t.Errorf("got %q, want quoted keys", data)
+	}
+	assertMap(t, mustParse(t, DefaultOptions(), string(data)), envMap)
+
+	for _, key := range []string{"", "a\nb", `both"'`} {
+		if data, err := Marshal(map[string]string{key: "x"}); err == nil {
+			t.Errorf("%q: got %q, want an error", key, data)
+		}
+	}
+}
+
+func TestDumpEnvQuotedKeys(t *testing.T) {
+	keepWd(t)
+	t.Setenv(EnvKey, "test")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{".env": "\"My.Weird-Key\"=1\nPLAIN=2\n"})
+
+	dump := filepath.Join(dir, "dump.env")
+	if err := DumpEnv(dump, filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, got, map[string]string{"My.Weird-Key": "1", "PLAIN": "2"})
+}
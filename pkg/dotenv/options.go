@@ -0,0 +1,270 @@
+package dotenv
+
+import "fmt"
+
+// Options configures a Loader
+type Options struct {
+	// SecretKeyPatterns marks keys containing any of the patterns
+	// (case-insensitive) as secret, files holding them are written with 0600
+	SecretKeyPatterns []string
+
+	// LineEnding ends the lines written by Marshal and WriteFile, "\n" or
+	// "\r\n". Empty means "\n"
+	LineEnding string
+
+	// SafeErrors hides values in parse errors and warnings, showing KEY=***.
+	// Values of keys matching SecretKeyPatterns are always hidden
+	SafeErrors bool
+
+	// ExpandInDoubleQuotes expands ${VAR} references in double-quoted values
+	ExpandInDoubleQuotes bool
+	// ExpandUnquoted expands ${VAR} references in unquoted values
+	ExpandUnquoted bool
+	// ExpandEscapesUnquoted decodes escapes like \n in unquoted values the
+	// way double-quoted values do
+	ExpandEscapesUnquoted bool
+
+	// RequireUTF8 rejects values holding invalid UTF-8 byte sequences, e.g.
+	// from a corrupted file
+	RequireUTF8 bool
+
+	// AllowHeredoc enables KEY=<<EOF values spanning lines up to a line
+	// equal to EOF
+	AllowHeredoc bool
+
+	// AllowTripleQuotes enables KEY="""value""" values read verbatim up to
+	// the closing triple quote, newlines included
+	AllowTripleQuotes bool
+
+	// AllowExportOnly skips shell-style export KEY1 KEY2 lines exporting
+	// variables without assigning them, these are errors otherwise. It takes
+	// precedence over the Whitespace separator style
+	AllowExportOnly bool
+
+	// StrictQuotes rejects quotes inside unquoted values (KEY=val"ue) and
+	// quoted values missing their closing quote (KEY="value), which text
+	// after the closing quote (KEY="a" b) is taken as. Such text starts the
+	// next statement otherwise
+	StrictQuotes bool
+
+	// RequireQuotedSpaces rejects unquoted values containing spaces, e.g.
+	// KEY=hello world
+	RequireQuotedSpaces bool
+
+	// EmptyMeansUnset makes FOO= unset FOO instead of setting it to an
+	// empty string
+	EmptyMeansUnset bool
+
+	// Transform rewrites each loaded value after expansion and before it is
+	// applied, returning false drops the key
+	Transform func(key, value string) (string, bool)
+
+	// Rename maps parsed keys to the names they are loaded under, keys not
+	// in the table are loaded unchanged
+	Rename map[string]string
+
+	// Deprecated maps old keys to their replacements, an old key still in use
+	// is reported by Loader.Warnings. With RemapDeprecated its value is also
+	// loaded under the replacement unless that one is assigned too
+	Deprecated      map[string]string
+	RemapDeprecated bool
+
+	// POSIXKeys rejects variable names not matching [a-zA-Z_][a-zA-Z0-9_]*
+	POSIXKeys bool
+
+	// AllowAppend enables KEY[]=value statements appending value to KEY,
+	// comma separated
+	AllowAppend bool
+
+	// RecoverUnterminated takes the rest of the line as the value of an
+	// unterminated quoted value and records a warning instead of failing. A
+	// quoted value closed on a later line only counts as unterminated
+	RecoverUnterminated bool
+
+	// CaseInsensitiveEnv lowercases the environment name in .env.<env>
+	// filenames, so APP_ENV=Production loads .env.production
+	CaseInsensitiveEnv bool
+
+	// DecodeBase64Prefix marks base64 encoded values decoded on load, e.g.
+	// "base64:" for CERT=base64:TU...
+	DecodeBase64Prefix string
+
+	// RequireBaseFile fails the load when the base env file is missing, the
+	// derived .local and .<env> files stay optional
+	RequireBaseFile bool
+
+	// Protect lists keys env files never set or unset, whether or not they
+	// are in the environment, e.g. names the application computes itself
+	Protect []string
+
+	// ListMergeKeys lists keys holding comma separated lists that are merged
+	// across the loaded files instead of replaced, e.g. PLUGINS=a,b in .env
+	// and PLUGINS=b,c in .env.local load as PLUGINS=a,b,c
+	ListMergeKeys []string
+
+	// Transactional applies the values of a load to the environment only
+	// once every file loaded successfully, a failing load leaves it
+	// untouched. Later files then no longer see the values of the former
+	// through SourceOS
+	Transactional bool
+
+	// StrictFiles fails LoadFiles and LoadManifest when a listed file is
+	// missing, by default missing files are skipped
+	StrictFiles bool
+
+	// LoadLocal reports whether a .local tier is loaded for the environment
+	// resolved when the tier is reached, e.g. to skip them in production.
+	// Nil loads them always
+	LoadLocal func(env string) bool
+
+	// DefaultsFile is an env file loaded before the base path tiers, with the
+	// lowest precedence, e.g. a committed .env.defaults
+	DefaultsFile string
+
+	// SecretFile is an env file loaded after the base path tiers, with the
+	// highest precedence. It must not be accessible by group or others
+	SecretFile string
+
+	// NoLeadingWhitespace rejects statements indented by whitespace
+	NoLeadingWhitespace bool
+
+	// SeparatorStyle locks the separator between keys and values
+	SeparatorStyle SeparatorStyle
+
+	// WarnUnknownKeys makes Unmarshal and LoadStruct return the keys that
+	// match no struct field, catching misspelled config keys
+	WarnUnknownKeys bool
+
+	// MaxFileSize limits the size of env files read from disk, in bytes.
+	// Zero means unlimited
+	MaxFileSize int64
+
+	// SkipInvalid skips the lines with an unexpected character in the
+	// variable name instead of failing, each skipped line is reported by
+	// Loader.Warnings
+	SkipInvalid bool
+
+	// ExpandPaths expands a leading ~ to the home directory and ${VAR}
+	// references to the environment in the name of every env file read, the
+	// LoadGlob pattern and the LoadManifest path included
+	ExpandPaths bool
+
+	// Debug receives trace events of the parser, e.g. "line 3: parsed KEY
+	// (double-quoted)", for troubleshooting unexpected results
+	Debug func(event string)
+
+	// OnFileError is called when an env file of the precedence scheme cannot
+	// be read or parsed. Returning nil skips the file and continues with the
+	// next one, returning an error aborts the load with it
+	OnFileError func(path string, err error) error
+
+	// ExpandVars holds extra variables available to ${VAR} references
+	ExpandVars map[string]string
+	// ExpandSources orders where ${VAR} references are looked up, the first
+	// source defining the variable wins. Nil means SourceFile, SourceVars,
+	// SourceResolver
+	ExpandSources []Source
+	// Resolver resolves ${VAR} references lazily, e.g. from a secret
+	// manager, reporting whether name was resolved. It is looked up as
+	// SourceResolver
+	Resolver func(name string) (string, bool)
+	// LiteralSigil marks an unquoted value starting with it as literal: the
+	// sigil is stripped and the rest is neither expanded nor unescaped,
+	// e.g. KEY=!${NOT_EXPANDED}. The sigil is a single byte such as '!',
+	// zero disables it
+	LiteralSigil byte
+	// BuiltinVars makes ${__FILE__} and ${__LINE__} expand to the file being
+	// parsed and the line of the statement
+	BuiltinVars bool
+	// ExpandAliases maps names referenced by ${VAR} to the variables they are
+	// looked up as, e.g. {"HOSTNAME": "HOST"}
+	ExpandAliases map[string]string
+	// TemplateExpansion runs values through text/template after ${VAR}
+	// expansion, with the environment and the variables assigned so far as
+	// data, e.g. KEY={{ upper .USER }}. Values are only expanded where
+	// ExpandInDoubleQuotes and ExpandUnquoted allow
+	TemplateExpansion bool
+	// OnUndefined sets what a reference to an undefined variable without a
+	// default expands to
+	OnUndefined UndefinedMode
+}
+
+// UndefinedMode is the handling of references to undefined variables
+type UndefinedMode int
+
+const (
+	// UndefinedEmpty expands the reference to an empty string
+	UndefinedEmpty UndefinedMode = iota
+	// UndefinedKeepLiteral leaves the reference unchanged, e.g. for values
+	// that are templates expanded later
+	UndefinedKeepLiteral
+	// UndefinedError fails the parse
+	UndefinedError
+)
+
+// Source is a place ${VAR} references are looked up in
+type Source int
+
+const (
+	// SourceFile is the variables assigned earlier in the same file
+	SourceFile Source = iota
+	// SourceVars is Options.ExpandVars
+	SourceVars
+	// SourceOS is the process environment
+	SourceOS
+	// SourceResolver is Options.Resolver
+	SourceResolver
+)
+
+var defaultExpandSources = []Source{SourceFile, SourceVars, SourceResolver}
+
+// SeparatorStyle is the separator allowed between keys and values
+type SeparatorStyle int
+
+const (
+	// EqualsOrColon allows both KEY=value and yaml-style KEY: value
+	EqualsOrColon SeparatorStyle = iota
+	// EqualsOnly allows KEY=value only
+	EqualsOnly
+	// ColonOnly allows KEY: value only
+	ColonOnly
+	// Whitespace allows space-separated KEY value only
+	Whitespace
+)
+
+func (s SeparatorStyle) allows(separator byte) bool {
+	switch s {
+	case EqualsOrColon:
+		return separator == '=' || separator == ':'
+	case EqualsOnly:
+		return separator == '='
+	case ColonOnly:
+		return separator == ':'
+	default:
+		return false
+	}
+}
+
+func (s SeparatorStyle) String() string {
+	switch s {
+	case EqualsOrColon:
+		return "equals-or-colon"
+	case EqualsOnly:
+		return "equals-only"
+	case ColonOnly:
+		return "colon-only"
+	case Whitespace:
+		return "whitespace"
+	default:
+		return fmt.Sprintf("SeparatorStyle(%d)", int(s))
+	}
+}
+
+// DefaultOptions returns the options used by the package-level functions.
+// Start from it rather than from a zero Options, which disables expansion
+func DefaultOptions() Options {
+	return Options{
+		ExpandInDoubleQuotes: true,
+		ExpandUnquoted:       true,
+	}
+}
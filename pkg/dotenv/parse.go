@@ -0,0 +1,190 @@
+package dotenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const documentMarker = "---"
+
+// Parse reads env statements from r using the default options
+func Parse(r io.Reader) (map[string]string, error) {
+	return NewLoader(DefaultOptions()).Parse(r)
+}
+
+// ReadFile parses filename using the default options without touching the
+// environment
+func ReadFile(filename string) (map[string]string, error) {
+	return NewLoader(DefaultOptions()).ReadFile(filename)
+}
+
+// Parse reads env statements from r
+func (l *Loader) Parse(r io.Reader) (map[string]string, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	envMap, err := l.parseBytes("", src)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.omitUnset(envMap), nil
+}
+
+// ReadFile parses filename without touching the environment, unlike the
+// LoadEnv tiers a missing file is an error
+func (l *Loader) ReadFile(filename string) (map[string]string, error) {
+	envMap, _, err := l.parseFile(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.omitUnset(envMap), nil
+}
+
+// IsValid parses src using the default options and returns the first error,
+// without applying anything
+func IsValid(src []byte) error {
+	return NewLoader(DefaultOptions()).IsValid(src)
+}
+
+// IsValid parses src and returns the first error, without applying anything
+func (l *Loader) IsValid(src []byte) error {
+	_, err := l.parseBytes("", src)
+	return err
+}
+
+// Pair is a single assignment of an env file
+type Pair struct {
+	Key, Value string
+}
+
+// ParsePairs reads every assignment from r in file order using the default
+// options
+func ParsePairs(r io.Reader) ([]Pair, error) {
+	return NewLoader(DefaultOptions()).ParsePairs(r)
+}
+
+// ParsePairs reads every assignment from r in file order, unlike Parse it
+// keeps the keys assigned more than once
+func (l *Loader) ParsePairs(r io.Reader) ([]Pair, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Pair
+	p := l.newParser("", src)
+	for {
+		key, value, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return out, nil
+		}
+		out = append(out, Pair{Key: key, Value: value})
+	}
+}
+
+// ParseArgs parses the leading KEY=VALUE arguments of args like env(1) does,
+// using the default options
+func ParseArgs(args []string) (map[string]string, []string, error) {
+	return NewLoader(DefaultOptions()).ParseArgs(args)
+}
+
+// ParseArgs parses the leading KEY=VALUE arguments of args like env(1) does,
+// each one with the quoting and expansion rules of env files. A # in an
+// argument is never a comment. It returns the assignments and the arguments
+// following them, starting at the first argument that is no assignment
+func (l *Loader) ParseArgs(args []string) (map[string]string, []string, error) {
+	vars := make(map[string]string)
+	for i, arg := range args {
+		name, _, ok := strings.Cut(arg, "=")
+		if !ok || validateKey(name) != nil {
+			return l.omitUnset(vars), args[i:], nil
+		}
+
+		p := l.newParser("", []byte(arg))
+		p.vars, p.arg = vars, true
+		if _, _, _, err := p.next(); err != nil {
+			return nil, nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		if p.cutset = p.getStatementStart(p.cutset); p.cutset != nil {
+			return nil, nil, fmt.Errorf("argument %d: unexpected %q after assignment", i+1, p.cutset)
+		}
+	}
+
+	return l.omitUnset(vars), nil, nil
+}
+
+// ParseMulti reads env documents separated by --- lines from r using the
+// default options
+func ParseMulti(r io.Reader) ([]map[string]string, error) {
+	return NewLoader(DefaultOptions()).ParseMulti(r)
+}
+
+// ParseMulti reads env documents separated by --- lines from r, each parsed
+// independently of the others. The marker is recognized even inside
+// multi-line quoted values
+func (l *Loader) ParseMulti(r io.Reader) ([]map[string]string, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []map[string]string
+	for i, section := range splitDocuments(src) {
+		envMap, err := l.parseBytes("", section)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		out = append(out, l.omitUnset(envMap))
+	}
+
+	return out, nil
+}
+
+// splitDocuments splits src on lines holding only the --- marker, blank
+// sections before the first and after the last marker are dropped
+func splitDocuments(src []byte) [][]byte {
+	var (
+		out   [][]byte
+		start int
+	)
+	for pos := 0; pos < len(src); {
+		end := bytes.IndexByte(src[pos:], '\n')
+		if end == -1 {
+			end = len(src)
+		} else {
+			end += pos
+		}
+
+		if string(bytes.TrimRightFunc(src[pos:end], unicode.IsSpace)) == documentMarker {
+			out = append(out, src[start:pos])
+			start = end
+		}
+		pos = end + 1
+	}
+
+	out = append(out, src[min(start, len(src)):])
+
+	// a marker opening or closing the input delimits no document
+	for len(out) > 1 && isBlank(out[0]) {
+		out = out[1:]
+	}
+	for len(out) > 1 && isBlank(out[len(out)-1]) {
+		out = out[:len(out)-1]
+	}
+
+	return out
+}
+
+func isBlank(src []byte) bool {
+	return len(bytes.TrimFunc(src, unicode.IsSpace)) == 0
+}
@@ -0,0 +1,141 @@
+package dotenv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMulti(t *testing.T) {
+	for _, src := range []string{
+		"A=1\n---\nB=2\n",
+		"A=1\n---\nB=2\n---\n",
+		"---\nA=1\n---\nB=2\n---\n\n",
+		"A=1\r\n--- \r\nB=2",
+	} {
+		docs, err := ParseMulti(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		if len(docs) != 2 {
+			t.Fatalf("%q: got %d documents %v, want 2", src, len(docs), docs)
+		}
+		assertMap(t, docs[0], map[string]string{"A": "1"})
+		assertMap(t, docs[1], map[string]string{"B": "2"})
+	}
+}
+
+func TestParseMultiIndependent(t *testing.T) {
+	unsetenv(t, "A")
+
+	docs, err := ParseMulti(strings.NewReader("A=1\n---\nB=${A}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := docs[1]["B"]; got != "" {
+		t.Errorf("B = %q, want the second document not to see A", got)
+	}
+
+	if _, err := ParseMulti(strings.NewReader("A=1\n---\nB=\"open\n")); err == nil || !strings.Contains(err.Error(), "document 2") {
+		t.Errorf("got %v, want an error naming document 2", err)
+	}
+}
+
+func TestReadFileDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadFile(dir); err == nil || !strings.Contains(err.Error(), "path is a directory") {
+		t.Errorf("got %v, want a directory error", err)
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxFileSize = 64
+	l := NewLoader(opts)
+
+	dir := t.TempDir()
+	large := "KEY=" + strings.Repeat("x", 100) + "\n"
+	writeFiles(t, dir, map[string]string{".env.small": "KEY=small\n", ".env.large": large})
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte(large)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Len() > int(opts.MaxFileSize) {
+		t.Fatalf("compressed fixture of %d bytes must fit the limit", compressed.Len())
+	}
+	writeFiles(t, dir, map[string]string{".env.gz": compressed.String()})
+
+	if got, err := l.ReadFile(filepath.Join(dir, ".env.small")); err != nil || got["KEY"] != "small" {
+		t.Errorf("small file: got %v, %v", got, err)
+	}
+	for name, want := range map[string]string{".env.large": "file size", ".env.gz": "decompressed size"} {
+		if _, err := l.ReadFile(filepath.Join(dir, name)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want a %s error", name, err, want)
+		}
+	}
+
+	if got, err := ReadFile(filepath.Join(dir, ".env.large")); err != nil || len(got["KEY"]) != 100 {
+		t.Errorf("unlimited: got %v, %v", got, err)
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	unsetenv(t, "VALID_A")
+	for _, src := range []string{"", "# comment\n", "VALID_A=1\nexport B='x'\nC=\"y\" # c\n"} {
+		if err := IsValid([]byte(src)); err != nil {
+			t.Errorf("%q: got %v, want valid", src, err)
+		}
+	}
+	if v, ok := os.LookupEnv("VALID_A"); ok {
+		t.Errorf("VALID_A = %q, want nothing applied", v)
+	}
+
+	for src, want := range map[string]string{
+		`A="x`:  "unterminated quoted value",
+		`A='x`:  "unterminated quoted value",
+		"A-B=1": "unexpected character",
+		"A":     "missing separator",
+	} {
+		if err := IsValid([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestParsePairs(t *testing.T) {
+	got, err := ParsePairs(strings.NewReader("A=1\nB=2\nA=3\n# c\nA=${B}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Pair{{"A", "1"}, {"B", "2"}, {"A", "3"}, {"A", "2"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	args := []string{"A=1 # not comment", "B='x#y'", "C=${A}", "D=#1", "cmd", "E=2", "--flag"}
+	vars, rest, err := ParseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMap(t, vars, map[string]string{"A": "1 # not comment", "B": "x#y", "C": "1 # not comment", "D": "#1"})
+	// assignments after the command belong to it
+	if want := []string{"cmd", "E=2", "--flag"}; !slices.Equal(rest, want) {
+		t.Errorf("got rest %q, want %q", rest, want)
+	}
+
+	if _, _, err := ParseArgs([]string{`A="1" # c`}); err == nil || !strings.Contains(err.Error(), "after assignment") {
+		t.Errorf("got %v, want an error for text after the quoted value", err)
+	}
+}
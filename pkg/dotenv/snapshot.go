@@ -0,0 +1,35 @@
+package dotenv
+
+import (
+	"os"
+	"strings"
+)
+
+// Snapshot records the environment and returns a function restoring it:
+// variables added since are unset, changed and removed ones are set back
+func Snapshot() func() {
+	saved := environ()
+
+	return func() {
+		for k := range environ() {
+			if _, ok := saved[k]; !ok {
+				_ = os.Unsetenv(k)
+			}
+		}
+		for k, v := range saved {
+			_ = os.Setenv(k, v)
+		}
+	}
+}
+
+func environ() map[string]string {
+	out := make(map[string]string)
+	for _, kv := range os.Environ() {
+		// skip Windows per-drive entries like "=C:=C:\"
+		if k, v, _ := strings.Cut(kv, "="); k != "" {
+			out[k] = v
+		}
+	}
+
+	return out
+}
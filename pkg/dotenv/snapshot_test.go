@@ -0,0 +1,28 @@
+package dotenv
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Setenv("SNAP_CHANGED", "before")
+	t.Setenv("SNAP_REMOVED", "kept")
+	unsetenv(t, "SNAP_ADDED")
+
+	restore := Snapshot()
+	_ = os.Setenv("SNAP_CHANGED", "after")
+	_ = os.Unsetenv("SNAP_REMOVED")
+	_ = os.Setenv("SNAP_ADDED", "new")
+	restore()
+
+	if got := os.Getenv("SNAP_CHANGED"); got != "before" {
+		t.Errorf("SNAP_CHANGED = %q, want before", got)
+	}
+	if got := os.Getenv("SNAP_REMOVED"); got != "kept" {
+		t.Errorf("SNAP_REMOVED = %q, want kept", got)
+	}
+	if got, ok := os.LookupEnv("SNAP_ADDED"); ok {
+		t.Errorf("SNAP_ADDED = %q, want unset", got)
+	}
+}
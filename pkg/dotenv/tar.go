@@ -0,0 +1,86 @@
+package dotenv
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/KoNekoD/rootpath/pkg/rootpath"
+	"io"
+	"os"
+	"path"
+)
+
+// LoadTarEntry loads the env file stored as entryName in the tar archive
+// tarPath, using the default options
+func LoadTarEntry(tarPath, entryName string) error {
+	return NewLoader(DefaultOptions()).LoadTarEntry(tarPath, entryName)
+}
+
+// LoadTarEntry loads the env file stored as entryName in the tar archive
+// tarPath like an env file, real environment variables win over it.
+// Gzip-compressed archives are decompressed transparently
+func (l *Loader) LoadTarEntry(tarPath, entryName string) error {
+	rootpath.MustChdir()
+
+	src, err := l.readTarEntry(tarPath, entryName)
+	if err != nil {
+		return err
+	}
+
+	envMap, err := l.parseBytes(tarPath+":"+entryName, src)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", tarPath, entryName, err)
+	}
+
+	dst := envTarget{loaded: l.loaded}
+	l.apply(envMap, nil, dst.existing(), dst, nil)
+
+	return nil
+}
+
+// readTarEntry returns the content of the regular file entryName of the tar
+// archive tarPath, enforcing Options.MaxFileSize on it
+func (l *Loader) readTarEntry(tarPath, entryName string) ([]byte, error) {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var r io.Reader = bufio.NewReader(file)
+	if magic, _ := r.(*bufio.Reader).Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tarPath, err)
+		}
+		defer func() { _ = zr.Close() }()
+		r = zr
+	}
+
+	want := path.Clean(entryName)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: entry %s not found in archive", tarPath, entryName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tarPath, err)
+		}
+		if path.Clean(hdr.Name) != want {
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s: entry %s is not a regular file", tarPath, entryName)
+		}
+		if maxSize := l.opts.MaxFileSize; maxSize > 0 && hdr.Size > maxSize {
+			return nil, fmt.Errorf("%s: entry %s size %d exceeds the limit of %d bytes", tarPath, entryName, hdr.Size, maxSize)
+		}
+
+		return io.ReadAll(tr)
+	}
+}
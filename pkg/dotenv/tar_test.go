@@ -0,0 +1,111 @@
+package dotenv
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTar writes a tar archive holding entries to filename, a nil content
+// is written as a directory entry
+func writeTar(t *testing.T, filename string, compress bool, entries map[string][]byte) {
+	t.Helper()
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var w io.Writer = file
+	if compress {
+		zw := gzip.NewWriter(file)
+		defer func() { _ = zw.Close() }()
+		w = zw
+	}
+
+	tw := tar.NewWriter(w)
+	for name, content := range entries {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if content == nil {
+			hdr.Mode, hdr.Typeflag = 0755, tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTarEntry(t *testing.T) {
+	keepWd(t)
+	dir := t.TempDir()
+	entries := map[string][]byte{
+		"config/":     nil,
+		"config/.env": []byte("TAR_A=1\nTAR_B=${TAR_A}2\n"),
+	}
+	writeTar(t, filepath.Join(dir, "env.tar"), false, entries)
+	writeTar(t, filepath.Join(dir, "env.tar.gz"), true, entries)
+
+	for _, archive := range []string{"env.tar", "env.tar.gz"} {
+		t.Run(archive, func(t *testing.T) {
+			unsetenv(t, "TAR_A", "TAR_B")
+
+			// the entry name is matched cleaned
+			if err := LoadTarEntry(filepath.Join(dir, archive), "./config/.env"); err != nil {
+				t.Fatal(err)
+			}
+			for k, want := range map[string]string{"TAR_A": "1", "TAR_B": "12"} {
+				if got := os.Getenv(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("environment wins", func(t *testing.T) {
+		unsetenv(t, "TAR_B")
+		t.Setenv("TAR_A", "os")
+
+		if err := LoadTarEntry(filepath.Join(dir, "env.tar"), "config/.env"); err != nil {
+			t.Fatal(err)
+		}
+		if got := os.Getenv("TAR_A"); got != "os" {
+			t.Errorf("TAR_A = %q, want %q", got, "os")
+		}
+	})
+
+	t.Run("missing archive", func(t *testing.T) {
+		if err := LoadTarEntry(filepath.Join(dir, "missing.tar"), "config/.env"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("got %v, want fs.ErrNotExist", err)
+		}
+	})
+
+	for _, tt := range []struct {
+		name, entry, want string
+	}{
+		{"missing entry", ".env", "entry .env not found in archive"},
+		{"directory entry", "config", "entry config is not a regular file"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "TAR_A", "TAR_B")
+
+			if err := LoadTarEntry(filepath.Join(dir, "env.tar"), tt.entry); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want it to mention %q", err, tt.want)
+			}
+			if got, ok := os.LookupEnv("TAR_A"); ok {
+				t.Errorf("TAR_A = %q, want unset", got)
+			}
+		})
+	}
+}
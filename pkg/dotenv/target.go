@@ -0,0 +1,149 @@
+package dotenv
+
+import (
+	"os"
+	"sync"
+)
+
+// target is where loaded values are applied to
+type target interface {
+	// existing returns the keys that must not be overridden by env files
+	existing() map[string]struct{}
+	// lookup returns the value of key as applied to the target so far
+	lookup(key string) (string, bool)
+	set(key, value string)
+	unset(key string)
+}
+
+// envTarget applies values to the process environment, keys set by the
+// loader itself may be overridden by later loads
+type envTarget struct {
+	loaded map[string]struct{}
+}
+
+func (t envTarget) existing() map[string]struct{} {
+	out := make(map[string]struct{})
+	for k := range environ() {
+		if _, ok := t.loaded[k]; !ok {
+			out[k] = struct{}{}
+		}
+	}
+
+	return out
+}
+
+func (t envTarget) lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+func (t envTarget) set(key, value string) {
+	_ = os.Setenv(key, value)
+	t.loaded[key] = struct{}{}
+}
+
+func (t envTarget) unset(key string) {
+	_ = os.Unsetenv(key)
+	delete(t.loaded, key)
+}
+
+// mapTarget collects values into a map
+type mapTarget map[string]string
+
+func (t mapTarget) existing() map[string]struct{} {
+	return nil
+}
+
+func (t mapTarget) lookup(key string) (string, bool) {
+	value, ok := t[key]
+	return value, ok
+}
+
+func (t mapTarget) set(key, value string) {
+	t[key] = value
+}
+
+func (t mapTarget) unset(key string) {
+	delete(t, key)
+}
+
+// syncMapTarget stores values into a sync.Map, keys already stored are kept
+type syncMapTarget struct {
+	m *sync.Map
+}
+
+func (t syncMapTarget) existing() map[string]struct{} {
+	out := make(map[string]struct{})
+	t.m.Range(func(k, _ any) bool {
+		if key, ok := k.(string); ok {
+			out[key] = struct{}{}
+		}
+		return true
+	})
+
+	return out
+}
+
+func (t syncMapTarget) lookup(key string) (string, bool) {
+	value, ok := t.m.Load(key)
+	if !ok {
+		return "", false
+	}
+	s, ok := value.(string)
+
+	return s, ok
+}
+
+func (t syncMapTarget) set(key, value string) {
+	t.m.Store(key, value)
+}
+
+func (t syncMapTarget) unset(key string) {
+	t.m.Delete(key)
+}
+
+// deferredTarget records values to apply them to another target later, the
+// existing keys are those of that target at creation
+type deferredTarget struct {
+	dst  target
+	keys map[string]struct{}
+	ops  *[]func(dst target)
+	// values holds the recorded values, nil for the unset keys
+	values map[string]*string
+}
+
+func newDeferredTarget(dst target) deferredTarget {
+	return deferredTarget{dst: dst, keys: dst.existing(), ops: new([]func(dst target)), values: make(map[string]*string)}
+}
+
+func (t deferredTarget) existing() map[string]struct{} {
+	return t.keys
+}
+
+func (t deferredTarget) lookup(key string) (string, bool) {
+	value, ok := t.values[key]
+	if !ok {
+		return t.dst.lookup(key)
+	}
+	if value == nil {
+		return "", false
+	}
+
+	return *value, true
+}
+
+func (t deferredTarget) set(key, value string) {
+	t.values[key] = &value
+	*t.ops = append(*t.ops, func(dst target) { dst.set(key, value) })
+}
+
+func (t deferredTarget) unset(key string) {
+	t.values[key] = nil
+	*t.ops = append(*t.ops, func(dst target) { dst.unset(key) })
+}
+
+// replay applies the recorded values to dst in order
+func (t deferredTarget) replay(dst target) {
+	for _, op := range *t.ops {
+		op(dst)
+	}
+}
@@ -0,0 +1,49 @@
+package dotenv
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+)
+
+const templateDelim = "{{"
+
+// templateFuncs are the functions available to values with
+// Options.TemplateExpansion besides the text/template builtins
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"default": func(fallback, v string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	},
+}
+
+// executeTemplate runs v through text/template with the environment,
+// Options.ExpandVars and the variables assigned so far as data, the latter
+// winning. Missing variables execute to an empty string
+func (p *parser) executeTemplate(v string) (string, error) {
+	if !strings.Contains(v, templateDelim) {
+		return v, nil
+	}
+
+	tmpl, err := template.New(p.key).Funcs(templateFuncs).Option("missingkey=zero").Parse(v)
+	if err != nil {
+		return "", fmt.Errorf("line %d: %w", p.lineAt(p.cutset), err)
+	}
+
+	data := environ()
+	maps.Copy(data, p.opts.ExpandVars)
+	maps.Copy(data, p.vars)
+
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("line %d: %w", p.lineAt(p.cutset), err)
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,34 @@
+package dotenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateExpansion(t *testing.T) {
+	t.Setenv("TMPL_USER", "alice")
+	const src = "NAME=bob\nA={{ upper .NAME }}\nB=\"{{ upper .TMPL_USER }}\"\nC='{{ upper .NAME }}'\nD={{ .TMPL_MISSING | default \"x\" }}\n"
+
+	assertMap(t, mustParse(t, DefaultOptions(), src), map[string]string{
+		"NAME": "bob",
+		"A":    "{{ upper .NAME }}",
+		"B":    "{{ upper .TMPL_USER }}",
+		"C":    "{{ upper .NAME }}",
+		"D":    `{{ .TMPL_MISSING | default "x" }}`,
+	})
+
+	// single-quoted values are never expanded
+	opts := DefaultOptions()
+	opts.TemplateExpansion = true
+	assertMap(t, mustParse(t, opts, src), map[string]string{
+		"NAME": "bob",
+		"A":    "BOB",
+		"B":    "ALICE",
+		"C":    "{{ upper .NAME }}",
+		"D":    "x",
+	})
+
+	if err := parseErr(t, opts, "A=1\nB={{ upper\n"); !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %q, want the line of the broken template", err)
+	}
+}
@@ -0,0 +1,202 @@
+package dotenv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/KoNekoD/rootpath/pkg/rootpath"
+	"strconv"
+	"strings"
+)
+
+// LoadTOML loads the keys of table in the TOML file path like an env file,
+// using the default options
+func LoadTOML(path, table string) error {
+	return NewLoader(DefaultOptions()).LoadTOML(path, table)
+}
+
+// LoadTOML loads the keys of table in the TOML file path like an env file,
+// real environment variables win over them. Values other than strings are
+// loaded as written, arrays as their comma separated elements.
+//
+// Only single-line values are supported in table, the other tables are
+// skipped unparsed
+func (l *Loader) LoadTOML(path, table string) error {
+	rootpath.MustChdir()
+
+	src, err := l.readSource(path)
+	if err != nil {
+		return err
+	}
+
+	envMap, err := parseTOMLTable(src, table)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	dst := envTarget{loaded: l.loaded}
+	l.apply(envMap, nil, dst.existing(), dst, nil)
+
+	return nil
+}
+
+// parseTOMLTable returns the key/values of table in TOML src
+func parseTOMLTable(src []byte, table string) (map[string]string, error) {
+	out := make(map[string]string)
+
+	var current string
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == charComment {
+			continue
+		}
+
+		if text[0] == '[' {
+			header, _, _ := strings.Cut(text, "#")
+			header = strings.TrimSpace(header)
+			if strings.HasPrefix(header, "[[") {
+				// array of tables never matches a plain table
+				current = header
+				continue
+			}
+			if strings.HasSuffix(header, "]") {
+				current = tomlTableName(header[1 : len(header)-1])
+				continue
+			}
+			// e.g. a nested array continued from the line before
+			if current != table {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: unterminated table header %q", line, text)
+		}
+
+		// the other tables may hold values this parser does not support
+		if current != table {
+			continue
+		}
+		key, value, err := parseTOMLKeyValue(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		out[key] = value
+	}
+
+	return out, scanner.Err()
+}
+
+// tomlTableName normalizes the spaces around the dots of a table name
+func tomlTableName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// parseTOMLKeyValue parses a key = value line
+func parseTOMLKeyValue(text string) (key, value string, err error) {
+	key, rest, err := parseTOMLKey(text)
+	if err != nil {
+		return "", "", err
+	}
+
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "=") {
+		return "", "", fmt.Errorf("missing = after key %q", key)
+	}
+
+	value, rest, err = parseTOMLValue(strings.TrimSpace(rest[1:]))
+	if err != nil {
+		return "", "", fmt.Errorf("value of %s: %w", key, err)
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != charComment {
+		return "", "", fmt.Errorf("unexpected %q after value of %s", rest, key)
+	}
+
+	return key, value, nil
+}
+
+func parseTOMLKey(text string) (key, rest string, err error) {
+	if quote, isQuoted := hasQuotePrefix([]byte(text)); isQuoted {
+		return parseTOMLString(text, quote)
+	}
+
+	end := strings.IndexFunc(text, func(r rune) bool { return r == '=' || r == ' ' || r == '\t' })
+	if end <= 0 {
+		return "", "", fmt.Errorf("missing key in %q", text)
+	}
+
+	return text[:end], text[end:], nil
+}
+
+func parseTOMLValue(text string) (value, rest string, err error) {
+	if quote, isQuoted := hasQuotePrefix([]byte(text)); isQuoted {
+		if strings.HasPrefix(text, strings.Repeat(string(quote), 3)) {
+			return "", "", fmt.Errorf("multi-line strings are not supported")
+		}
+		return parseTOMLString(text, quote)
+	}
+
+	if strings.HasPrefix(text, "[") {
+		return parseTOMLArray(text[1:])
+	}
+
+	end := strings.IndexAny(text, " \t#,]")
+	if end == -1 {
+		end = len(text)
+	}
+	if end == 0 {
+		return "", "", fmt.Errorf("missing value")
+	}
+
+	return text[:end], text[end:], nil
+}
+
+// parseTOMLArray parses the elements of an array after its opening bracket,
+// returning them comma separated
+func parseTOMLArray(text string) (value, rest string, err error) {
+	var items []string
+	for {
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "]") {
+			return strings.Join(items, listSeparator), text[1:], nil
+		}
+		if text == "" {
+			return "", "", fmt.Errorf("multi-line arrays are not supported")
+		}
+
+		item, after, err := parseTOMLValue(text)
+		if err != nil {
+			return "", "", err
+		}
+		items = append(items, item)
+
+		text = strings.TrimSpace(after)
+		if strings.HasPrefix(text, ",") {
+			text = text[1:]
+		}
+	}
+}
+
+// parseTOMLString parses a basic "string" or a literal 'string'
+func parseTOMLString(text string, quote byte) (value, rest string, err error) {
+	for i := 1; i < len(text); i++ {
+		if text[i] != quote || quote == prefixDoubleQuote && isEscaped([]byte(text), i) {
+			continue
+		}
+		if quote == prefixSingleQuote {
+			return text[1:i], text[i+1:], nil
+		}
+
+		value, err = strconv.Unquote(text[:i+1])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s: %w", text[:i+1], err)
+		}
+
+		return value, text[i+1:], nil
+	}
+
+	return "", "", fmt.Errorf("unterminated string %s", text)
+}
@@ -0,0 +1,51 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTOML(t *testing.T) {
+	keepWd(t)
+	unsetenv(t, "title", "TOML_NAME", "TOML_PORT", "TOML_DEBUG", "TOML_HOSTS", "TOML_DB_HOST")
+	t.Setenv("TOML_DEBUG", "env")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// relative paths resolve against the module root like LoadEnv
+	if err := LoadTOML("pkg/dotenv/testdata/config.toml", "app"); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		"TOML_NAME":  "demo",
+		"TOML_PORT":  "8080",
+		"TOML_DEBUG": "env",
+		"TOML_HOSTS": "a,b",
+	} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	for _, k := range []string{"title", "TOML_DB_HOST"} {
+		if v, ok := os.LookupEnv(k); ok {
+			t.Errorf("%s = %q, want unset outside the table", k, v)
+		}
+	}
+
+	if err := LoadTOML(filepath.Join(wd, "testdata", "config.toml"), "app.db"); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("TOML_DB_HOST"); got != "localhost" {
+		t.Errorf("TOML_DB_HOST = %q, want localhost", got)
+	}
+
+	// values unsupported by the parser fail in the loaded table only
+	if err := LoadTOML(filepath.Join(wd, "testdata", "config.toml"), "package"); err == nil || !strings.Contains(err.Error(), "line 14: value of authors") {
+		t.Errorf("got %v, want an unsupported value error", err)
+	}
+}
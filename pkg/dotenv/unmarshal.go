@@ -0,0 +1,198 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagName = "env"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Unmarshal stores env map values into the struct pointed to by v. Fields
+// are matched by their env tag or else by their upper-cased name, fields
+// tagged env:"-" are skipped. Fields of nested structs are matched by dotted
+// keys, e.g. DB.HOST or db.host
+func Unmarshal(envMap map[string]string, v any) error {
+	_, err := NewLoader(DefaultOptions()).Unmarshal(envMap, v)
+	return err
+}
+
+// LoadStruct merges env files by path, in order of precedence, into the
+// struct pointed to by v without touching the environment. Real environment
+// variables win over env files
+func LoadStruct(v any, path ...string) error {
+	_, err := NewLoader(DefaultOptions()).LoadStruct(v, path...)
+	return err
+}
+
+// Unmarshal stores env map values into the struct pointed to by v. With
+// Options.WarnUnknownKeys it returns the sorted keys matching no field
+func (l *Loader) Unmarshal(envMap map[string]string, v any) (unknown []string, err error) {
+	lookup := func(key string) (string, bool) {
+		value, ok := envMap[key]
+		return value, ok
+	}
+
+	return l.unmarshal(lookup, envMap, v)
+}
+
+// LoadStruct merges env files by path, in order of precedence, into the
+// struct pointed to by v without touching the environment. Real environment
+// variables win over env files. With Options.WarnUnknownKeys it returns the
+// sorted file keys matching no field
+func (l *Loader) LoadStruct(v any, path ...string) (unknown []string, err error) {
+	envMap, err := l.readFiles(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := envMap[key]
+		return value, ok
+	}
+
+	return l.unmarshal(lookup, envMap, v)
+}
+
+func (l *Loader) unmarshal(lookup func(key string) (string, bool), envMap map[string]string, v any) ([]string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("unmarshal target must be a non-nil pointer to a struct")
+	}
+
+	used := make(map[string]struct{})
+	if err := decodeStruct(rv.Elem(), "", lookup, used); err != nil {
+		var convErr *FieldConversionError
+		if errors.As(err, &convErr) && (l.opts.SafeErrors || l.isSecretKey(convErr.Key)) {
+			convErr.Err = &redactedError{err: convErr.Err}
+			convErr.Value = redactedValue
+		}
+		return nil, err
+	}
+
+	if !l.opts.WarnUnknownKeys {
+		return nil, nil
+	}
+
+	var unknown []string
+	for k := range envMap {
+		if _, ok := used[k]; !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	slices.Sort(unknown)
+
+	return unknown, nil
+}
+
+// decodeStruct stores values into the fields of rv, prefix is the dotted key
+// path of rv inside the bound struct
+func decodeStruct(rv reflect.Value, prefix string, lookup func(key string) (string, bool), used map[string]struct{}) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := field.Tag.Get(tagName)
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToUpper(field.Name)
+		}
+		key = prefix + key
+
+		// nested structs bind dotted keys, e.g. DB.HOST to DB.Host
+		if field.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(rv.Field(i), key+".", lookup, used); err != nil {
+				return err
+			}
+			continue
+		}
+
+		used[key] = struct{}{}
+		value, ok := lookup(key)
+		if !ok && prefix != "" {
+			// dotted keys are often written lower-case, e.g. db.host
+			key = strings.ToLower(key)
+			used[key] = struct{}{}
+			value, ok = lookup(key)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := setField(rv.Field(i), value); err != nil {
+			return &FieldConversionError{Key: key, Value: value, Type: field.Type, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func setField(fv reflect.Value, value string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, listSeparator) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items).Convert(fv.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalNested(t *testing.T) {
+	var c struct {
+		DB struct {
+			Host string
+			Port int
+			User string
+		}
+		Cache struct {
+			Addr string
+		} `env:"REDIS"`
+	}
+
+	envMap := mustParse(t, DefaultOptions(), "DB.HOST=db\ndb.port=5432\nDB.USER=upper\ndb.user=lower\nredis.addr=cache:6379\n")
+	if err := Unmarshal(envMap, &c); err != nil {
+		t.Fatal(err)
+	}
+	// the upper-case key wins over the lower-case one
+	if c.DB.Host != "db" || c.DB.Port != 5432 || c.DB.User != "upper" || c.Cache.Addr != "cache:6379" {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestWarnUnknownKeys(t *testing.T) {
+	type config struct {
+		Host string
+		Port int `env:"DB_PORT"`
+	}
+	envMap := map[string]string{"HOST": "localhost", "DB_PORT": "5432", "DB_PROT": "typo", "EXTRA": "x"}
+
+	opts := DefaultOptions()
+	opts.WarnUnknownKeys = true
+
+	var c config
+	unknown, err := NewLoader(opts).Unmarshal(envMap, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "localhost" || c.Port != 5432 {
+		t.Errorf("got %+v", c)
+	}
+	if want := []string{"DB_PROT", "EXTRA"}; !slices.Equal(unknown, want) {
+		t.Errorf("unknown = %v, want %v", unknown, want)
+	}
+
+	unknown, err = NewLoader(DefaultOptions()).Unmarshal(envMap, &c)
+	if err != nil || unknown != nil {
+		t.Errorf("got %v, %v without WarnUnknownKeys, want none", unknown, err)
+	}
+}
+
+func TestFieldConversionError(t *testing.T) {
+	var c struct {
+		Port    int
+		Debug   bool
+		Timeout time.Duration
+	}
+
+	for _, tt := range []struct {
+		key, value string
+		typ        reflect.Type
+	}{
+		{"PORT", "abc", reflect.TypeOf(0)},
+		{"DEBUG", "maybe", reflect.TypeOf(false)},
+		{"TIMEOUT", "5 parsecs", reflect.TypeOf(time.Duration(0))},
+	} {
+		err := Unmarshal(map[string]string{tt.key: tt.value}, &c)
+
+		var convErr *FieldConversionError
+		if !errors.As(err, &convErr) {
+			t.Fatalf("%s: got %v, want a FieldConversionError", tt.key, err)
+		}
+		if convErr.Key != tt.key || convErr.Value != tt.value || convErr.Type != tt.typ {
+			t.Errorf("%s: got %+v", tt.key, convErr)
+		}
+		if want := fmt.Sprintf("cannot convert %s=%q to %s", tt.key, tt.value, tt.typ); !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: got %q, want it to start with %q", tt.key, err, want)
+		}
+	}
+}
+
+func TestUnmarshalRedaction(t *testing.T) {
+	type config struct {
+		DB struct {
+			Port     int
+			Password int
+		}
+		Timeout time.Duration
+	}
+
+	safe := DefaultOptions()
+	safe.SafeErrors = true
+	secret := DefaultOptions()
+	secret.SecretKeyPatterns = []string{"PASSWORD"}
+
+	for _, tt := range []struct {
+		name   string
+		opts   Options
+		envMap map[string]string
+		want   string
+		cause  error
+	}{
+		{"safe errors", safe, map[string]string{"DB.PORT": "s3cr3t"}, `cannot convert DB.PORT="***" to int: strconv.ParseInt: invalid syntax`, strconv.ErrSyntax},
+		{"empty value", safe, map[string]string{"DB.PORT": ""}, `cannot convert DB.PORT="***" to int: strconv.ParseInt: invalid syntax`, strconv.ErrSyntax},
+		{"short value", safe, map[string]string{"DB.PORT": "t"}, `cannot convert DB.PORT="***" to int: strconv.ParseInt: invalid syntax`, strconv.ErrSyntax},
+		{"duration", safe, map[string]string{"TIMEOUT": "x"}, `cannot convert TIMEOUT="***" to time.Duration: invalid value`, nil},
+		{"secret key", secret, map[string]string{"DB.PASSWORD": "hunter2"}, `cannot convert DB.PASSWORD="***" to int: strconv.ParseInt: invalid syntax`, strconv.ErrSyntax},
+		{"plain key", secret, map[string]string{"DB.PORT": "abc"}, `cannot convert DB.PORT="abc" to int: strconv.ParseInt: parsing "abc": invalid syntax`, strconv.ErrSyntax},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var c config
+			_, err := NewLoader(tt.opts).Unmarshal(tt.envMap, &c)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got %v, want %s", err, tt.want)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("got %v, want it to wrap %v", err, tt.cause)
+			}
+		})
+	}
+}
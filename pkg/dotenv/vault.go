@@ -0,0 +1,125 @@
+package dotenv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/KoNekoD/rootpath/pkg/rootpath"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// VaultKeyEnv is the variable LoadVault reads the decryption keys from
+const VaultKeyEnv = "DOTENV_KEY"
+
+const (
+	vaultKeyPrefix        = "key_"
+	vaultKeyLength        = 64
+	vaultEntryPrefix      = "DOTENV_VAULT_"
+	vaultEnvironmentParam = "environment"
+)
+
+// LoadVault decrypts a .env.vault file with the keys of DOTENV_KEY and loads
+// the result like an env file, using the default options
+func LoadVault(path string) error {
+	return NewLoader(DefaultOptions()).LoadVault(path)
+}
+
+// LoadVault decrypts a .env.vault file with the keys of DOTENV_KEY and loads
+// the result like an env file.
+//
+// The vault is an env file of DOTENV_VAULT_<ENVIRONMENT> entries, each holding
+// base64 of a 12 byte nonce followed by the AES-256-GCM sealed env file.
+// DOTENV_KEY holds comma separated keys of the form
+// dotenv://:key_<64 hex digits>@host/vault/.env.vault?environment=<environment>,
+// the first key decrypting its environment entry wins
+func (l *Loader) LoadVault(path string) error {
+	rootpath.MustChdir()
+
+	keys := os.Getenv(VaultKeyEnv)
+	if keys == "" {
+		return fmt.Errorf("%s is not set", VaultKeyEnv)
+	}
+
+	// entries are plain base64, parse them without expansion
+	vault, err := NewLoader(Options{}).ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range strings.Split(keys, ",") {
+		plaintext, err := decryptVault(vault, strings.TrimSpace(key))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		envMap, err := l.parseBytes(path, plaintext)
+		if err != nil {
+			return err
+		}
+		dst := envTarget{loaded: l.loaded}
+		l.apply(envMap, nil, dst.existing(), dst, nil)
+
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", path, errors.Join(errs...))
+}
+
+// decryptVault decrypts the entry of vault selected by key
+func decryptVault(vault map[string]string, key string) ([]byte, error) {
+	u, err := url.Parse(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", VaultKeyEnv, err)
+	}
+
+	password, _ := u.User.Password()
+	if !strings.HasPrefix(password, vaultKeyPrefix) || len(password) != len(vaultKeyPrefix)+vaultKeyLength {
+		return nil, fmt.Errorf("invalid %s: expected %s followed by %d hex digits", VaultKeyEnv, vaultKeyPrefix, vaultKeyLength)
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(password, vaultKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", VaultKeyEnv, err)
+	}
+
+	environment := u.Query().Get(vaultEnvironmentParam)
+	if environment == "" {
+		return nil, fmt.Errorf("invalid %s: missing %s parameter", VaultKeyEnv, vaultEnvironmentParam)
+	}
+	name := vaultEntryPrefix + strings.ToUpper(environment)
+	entry, ok := vault[name]
+	if !ok {
+		return nil, fmt.Errorf("missing %s entry", name)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 value of %s: %w", name, err)
+	}
+
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%s is too short", name)
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt %s: %w", name, err)
+	}
+
+	return plaintext, nil
+}
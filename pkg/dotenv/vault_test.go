@@ -0,0 +1,61 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	vaultTestKey  = "key_6f1c4a0e9b3d7f2a58c1e0b4d9a7f3621c5e8b0a4d7f2e9c1b6a3d8f0e5c7b29"
+	vaultWrongKey = "key_0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func vaultDSN(key, environment string) string {
+	return "dotenv://:" + key + "@dotenv.org/vault/.env.vault?environment=" + environment
+}
+
+func TestLoadVault(t *testing.T) {
+	keepWd(t)
+	path, err := filepath.Abs("testdata/.env.vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("known key", func(t *testing.T) {
+		unsetenv(t, "VAULT_GREETING", "VAULT_REF")
+		// keys failing to decrypt are skipped for the next one
+		t.Setenv(VaultKeyEnv, vaultDSN(vaultWrongKey, "production")+","+vaultDSN(vaultTestKey, "production"))
+
+		if err := LoadVault(path); err != nil {
+			t.Fatal(err)
+		}
+		for k, want := range map[string]string{"VAULT_GREETING": "hello vault", "VAULT_REF": "hello vault!"} {
+			if got := os.Getenv(k); got != want {
+				t.Errorf("%s = %q, want %q", k, got, want)
+			}
+		}
+	})
+
+	for _, tt := range []struct {
+		name, key, want string
+	}{
+		{"wrong key", vaultDSN(vaultWrongKey, "production"), "cannot decrypt DOTENV_VAULT_PRODUCTION"},
+		{"missing environment", vaultDSN(vaultTestKey, "staging"), "missing DOTENV_VAULT_STAGING entry"},
+		{"malformed key", vaultDSN("key_xyz", "production"), "expected key_ followed by 64 hex digits"},
+		{"no key", "", "DOTENV_KEY is not set"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetenv(t, "VAULT_GREETING", "VAULT_REF")
+			t.Setenv(VaultKeyEnv, tt.key)
+
+			if err := LoadVault(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want it to mention %q", err, tt.want)
+			}
+			if got, ok := os.LookupEnv("VAULT_GREETING"); ok {
+				t.Errorf("VAULT_GREETING = %q, want unset", got)
+			}
+		})
+	}
+}
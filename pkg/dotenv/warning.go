@@ -0,0 +1,18 @@
+package dotenv
+
+import "fmt"
+
+// Warning describes a non-fatal problem found while parsing
+type Warning struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (w Warning) String() string {
+	if w.File == "" {
+		return fmt.Sprintf("line %d: %s", w.Line, w.Msg)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", w.File, w.Line, w.Msg)
+}